@@ -1,7 +1,9 @@
 package main
 
 import (
+	"fmt"
 	"math"
+	"math/big"
 )
 
 const eps = 1e-12
@@ -62,3 +64,233 @@ func rref(A [][]float64) [][]float64 {
 
 	return A
 }
+
+// buildAugmentedRat is buildAugmentedMatrix with exact rational entries.
+func buildAugmentedRat(A [][]bool, b []int) [][]*big.Rat {
+	m := len(A)
+	n := len(A[0])
+
+	if len(b) != m {
+		panic("row count of A does not match length of b")
+	}
+
+	aug := make([][]*big.Rat, m)
+	for i := 0; i < m; i++ {
+		row := make([]*big.Rat, n+1)
+		for j := 0; j < n; j++ {
+			row[j] = new(big.Rat)
+			if A[i][j] {
+				row[j].SetInt64(1)
+			}
+		}
+		row[n] = new(big.Rat).SetInt64(int64(b[i])) // RHS
+		aug[i] = row
+	}
+	return aug
+}
+
+// rrefRat is rref over exact rationals, so no pivot is ever mistaken for
+// zero or the other way round. It returns the reduced matrix and the pivot
+// column of each non-zero row, in row order.
+func rrefRat(A [][]*big.Rat) ([][]*big.Rat, []int) {
+	m := len(A)
+	n := len(A[0])
+
+	var pivots []int
+	r := 0 // current pivot row
+	f := new(big.Rat)
+
+	for c := 0; c < n-1 && r < m; c++ {
+		// Any non-zero entry is an exact pivot.
+		pivotRow := -1
+		for i := r; i < m; i++ {
+			if A[i][c].Sign() != 0 {
+				pivotRow = i
+				break
+			}
+		}
+		if pivotRow < 0 {
+			continue
+		}
+
+		A[r], A[pivotRow] = A[pivotRow], A[r]
+
+		// Normalize pivot row
+		inv := new(big.Rat).Inv(A[r][c])
+		for j := c; j < n; j++ {
+			A[r][j].Mul(A[r][j], inv)
+		}
+
+		// Eliminate all other rows
+		for i := 0; i < m; i++ {
+			if i == r || A[i][c].Sign() == 0 {
+				continue
+			}
+			factor := new(big.Rat).Set(A[i][c])
+			for j := c; j < n; j++ {
+				A[i][j].Sub(A[i][j], f.Mul(factor, A[r][j]))
+			}
+		}
+
+		pivots = append(pivots, c)
+		r++
+	}
+
+	return A, pivots
+}
+
+// exactSystem is a reduced system in fraction-free integer form. Pivot row r
+// reads
+//
+//	den[r] * x[pivots[r]] = rhs[r] - sum over k of coef[r][k] * x[free[k]]
+//
+// so the pivot variables follow from the free ones with integer arithmetic
+// only, and integrality is a remainder check.
+type exactSystem struct {
+	n          int
+	pivots     []int
+	free       []int
+	den, rhs   []int64
+	coef       [][]int64
+	consistent bool
+	reduced    [][]*big.Rat // the RREF, for values whose products overflow
+}
+
+// newExactSystem eliminates A·x = b exactly. Each reduced row is scaled by
+// the least common multiple of its denominators. It fails only when a
+// scaled coefficient does not fit in an int64.
+func newExactSystem(A [][]bool, b []int) (*exactSystem, error) {
+	n := len(A[0])
+	R, pivots := rrefRat(buildAugmentedRat(A, b))
+	s := &exactSystem{n: n, pivots: pivots, consistent: true, reduced: R}
+
+	isPivot := make([]bool, n)
+	for _, p := range pivots {
+		isPivot[p] = true
+	}
+	for j := 0; j < n; j++ {
+		if !isPivot[j] {
+			s.free = append(s.free, j)
+		}
+	}
+
+	// Rows below the pivots are all zero on the left; a non-zero right-hand
+	// side there means 0 = b.
+	for i := len(pivots); i < len(R); i++ {
+		if R[i][n].Sign() != 0 {
+			s.consistent = false
+		}
+	}
+
+	for r := range pivots {
+		row := R[r]
+		lcm := big.NewInt(1)
+		for _, v := range row {
+			d := v.Denom()
+			g := new(big.Int).GCD(nil, nil, lcm, d)
+			lcm.Mul(lcm, new(big.Int).Quo(d, g))
+		}
+
+		scaled := func(v *big.Rat) (int64, error) {
+			x := new(big.Int).Mul(v.Num(), new(big.Int).Quo(lcm, v.Denom()))
+			if !x.IsInt64() {
+				return 0, fmt.Errorf("row %d: coefficient %s does not fit in int64", r, x)
+			}
+			return x.Int64(), nil
+		}
+
+		den, err := scaled(big.NewRat(1, 1))
+		if err != nil {
+			return nil, err
+		}
+		rhs, err := scaled(row[n])
+		if err != nil {
+			return nil, err
+		}
+		coef := make([]int64, len(s.free))
+		for k, j := range s.free {
+			if coef[k], err = scaled(row[j]); err != nil {
+				return nil, err
+			}
+		}
+		s.den = append(s.den, den)
+		s.rhs = append(s.rhs, rhs)
+		s.coef = append(s.coef, coef)
+	}
+	return s, nil
+}
+
+// solve returns the full solution for the given free-variable values, or
+// false when a pivot variable would be fractional or negative.
+func (s *exactSystem) solve(vals []int64) ([]int64, bool) {
+	x := make([]int64, s.n)
+	for k, j := range s.free {
+		x[j] = vals[k]
+	}
+
+	for r, p := range s.pivots {
+		num, ok := s.rhs[r], true
+		for k, c := range s.coef[r] {
+			var prod int64
+			if prod, ok = mulInt64(c, vals[k]); !ok {
+				break
+			}
+			if num, ok = subInt64(num, prod); !ok {
+				break
+			}
+		}
+		if !ok {
+			return s.solveRat(vals)
+		}
+		if num%s.den[r] != 0 {
+			return nil, false // not integer
+		}
+		if x[p] = num / s.den[r]; x[p] < 0 {
+			return nil, false // negative
+		}
+	}
+	return x, true
+}
+
+// solveRat is solve in big.Rat arithmetic, used when int64 products would
+// overflow.
+func (s *exactSystem) solveRat(vals []int64) ([]int64, bool) {
+	x := make([]int64, s.n)
+	for k, j := range s.free {
+		x[j] = vals[k]
+	}
+
+	v, t := new(big.Rat), new(big.Rat)
+	for r, p := range s.pivots {
+		v.Set(s.reduced[r][s.n])
+		for k, j := range s.free {
+			v.Sub(v, t.Mul(s.reduced[r][j], t.SetInt64(vals[k])))
+		}
+		if !v.IsInt() || v.Sign() < 0 || !v.Num().IsInt64() {
+			return nil, false
+		}
+		x[p] = v.Num().Int64()
+	}
+	return x, true
+}
+
+// mulInt64 returns a*b and reports false on overflow.
+func mulInt64(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	p := a * b
+	if p/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, false
+	}
+	return p, true
+}
+
+// subInt64 returns a-b and reports false on overflow.
+func subInt64(a, b int64) (int64, bool) {
+	d := a - b
+	if (b > 0 && d > a) || (b < 0 && d < a) {
+		return 0, false
+	}
+	return d, true
+}
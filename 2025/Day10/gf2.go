@@ -0,0 +1,223 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// bitset is a row over GF(2), one bit per column.
+type bitset []uint64
+
+func newBitset(n int) bitset {
+	return make(bitset, (n+63)/64)
+}
+
+func (s bitset) get(i int) bool {
+	return s[i/64]&(1<<(i%64)) != 0
+}
+
+func (s bitset) set(i int) {
+	s[i/64] |= 1 << (i % 64)
+}
+
+// xor adds t to s.
+func (s bitset) xor(t bitset) {
+	for k := range s {
+		s[k] ^= t[k]
+	}
+}
+
+func (s bitset) count() int {
+	n := 0
+	for _, w := range s {
+		n += bits.OnesCount64(w)
+	}
+	return n
+}
+
+// maxNullSpaceBits and maxPatternBits bound the two searches of
+// solveLights: at most 2^26 null-space vectors or 2^20 light patterns.
+const (
+	maxNullSpaceBits = 26
+	maxPatternBits   = 20
+)
+
+// errLightsUnreachable means no combination of buttons switches on exactly
+// the wanted lights.
+var errLightsUnreachable = errors.New("no buttons switch on exactly these lights")
+
+// solveLights finds the fewest button presses that turn on exactly the
+// lights in b, pressing each button at most once since twice cancels out.
+// It returns which buttons to press, errLightsUnreachable when no
+// combination works, or an error when the machine is too large to search.
+//
+// Gaussian elimination over GF(2) reduces A·x = b to row echelon form.
+// Every solution is then one particular solution plus a combination of the
+// null-space basis, one vector per free button, so only 2^free candidates
+// are tried instead of 2^buttons. When there are too many free buttons for
+// that, a breadth-first search over the 2^rank reachable light patterns is
+// used instead.
+func solveLights(A [][]bool, b []bool) ([]bool, error) {
+	m, n := len(A), len(A[0])
+
+	// Column n of each row holds the right-hand side.
+	rows := make([]bitset, m)
+	for i := range rows {
+		rows[i] = newBitset(n + 1)
+		for j := 0; j < n; j++ {
+			if A[i][j] {
+				rows[i].set(j)
+			}
+		}
+		if b[i] {
+			rows[i].set(n)
+		}
+	}
+
+	var pivots []int
+	r := 0
+	for c := 0; c < n && r < m; c++ {
+		p := -1
+		for i := r; i < m; i++ {
+			if rows[i].get(c) {
+				p = i
+				break
+			}
+		}
+		if p < 0 {
+			continue
+		}
+		rows[r], rows[p] = rows[p], rows[r]
+		for i := 0; i < m; i++ {
+			if i != r && rows[i].get(c) {
+				rows[i].xor(rows[r])
+			}
+		}
+		pivots = append(pivots, c)
+		r++
+	}
+
+	// A row with no buttons left but a light to switch reads 0 = 1.
+	for i := r; i < m; i++ {
+		if rows[i].get(n) {
+			return nil, errLightsUnreachable
+		}
+	}
+
+	isPivot := make([]bool, n)
+	for _, c := range pivots {
+		isPivot[c] = true
+	}
+	var free []int
+	for j := 0; j < n; j++ {
+		if !isPivot[j] {
+			free = append(free, j)
+		}
+	}
+
+	var best bitset
+	switch {
+	case len(free) <= maxNullSpaceBits:
+		best = nullSpaceSearch(rows, pivots, free, n)
+	case len(pivots) <= maxPatternBits:
+		best = patternSearch(rows, pivots, n)
+	default:
+		return nil, fmt.Errorf("%d free buttons and %d independent ones are too many to search", len(free), len(pivots))
+	}
+
+	pressed := make([]bool, n)
+	for j := range pressed {
+		pressed[j] = best.get(j)
+	}
+	return pressed, nil
+}
+
+// nullSpaceSearch tries every solution of the reduced rows and returns one
+// with the fewest presses.
+func nullSpaceSearch(rows []bitset, pivots, free []int, n int) bitset {
+	x := newBitset(n)
+	for i, c := range pivots {
+		if rows[i].get(n) {
+			x.set(c)
+		}
+	}
+	basis := make([]bitset, len(free))
+	for k, f := range free {
+		basis[k] = newBitset(n)
+		basis[k].set(f)
+		for i, c := range pivots {
+			if rows[i].get(f) {
+				basis[k].set(c)
+			}
+		}
+	}
+
+	// Walk the null space in Gray code order, so each step flips a single
+	// basis vector.
+	best := append(bitset(nil), x...)
+	bestCount := x.count()
+	for g := uint64(1); g < 1<<len(free); g++ {
+		x.xor(basis[bits.TrailingZeros64(g)])
+		if c := x.count(); c < bestCount {
+			copy(best, x)
+			bestCount = c
+		}
+	}
+	return best
+}
+
+// patternSearch finds the fewest presses by breadth-first search over light
+// patterns. In reduced form a pattern is its bits on the pivot rows, so a
+// button toggles the rank-bit pattern of its column, and the target is the
+// right-hand side. The first path to reach the target never presses a
+// button twice, since the two presses would cancel on a shorter path.
+func patternSearch(rows []bitset, pivots []int, n int) bitset {
+	pattern := func(c int) uint32 {
+		var p uint32
+		for i := range pivots {
+			if rows[i].get(c) {
+				p |= 1 << i
+			}
+		}
+		return p
+	}
+
+	// One button per distinct pattern is enough.
+	var buttons []int
+	var toggles []uint32
+	seen := make(map[uint32]bool)
+	for j := 0; j < n; j++ {
+		if p := pattern(j); p != 0 && !seen[p] {
+			seen[p] = true
+			buttons = append(buttons, j)
+			toggles = append(toggles, p)
+		}
+	}
+
+	// via[s] is the index in buttons of the last press reaching pattern s.
+	target := pattern(n)
+	via := make([]int32, 1<<len(pivots))
+	for s := range via {
+		via[s] = -1
+	}
+	queue := []uint32{0}
+	for len(queue) > 0 && target != 0 && via[target] < 0 {
+		s := queue[0]
+		queue = queue[1:]
+		for k, t := range toggles {
+			if u := s ^ t; u != 0 && via[u] < 0 {
+				via[u] = int32(k)
+				queue = append(queue, u)
+			}
+		}
+	}
+
+	x := newBitset(n)
+	for s := target; s != 0; {
+		k := via[s]
+		x.set(buttons[k])
+		s ^= toggles[k]
+	}
+	return x
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+)
+
+// The joltage part is the integer program
+//
+//	minimise sum(x)  subject to  A·x = b,  0 <= x <= u,  x integer
+//
+// where u[j] is the smallest target among the counters button j raises:
+// pressing it more often would overshoot that counter. solveILP runs branch
+// and bound on it. Every node solves the LP relaxation of its box exactly
+// with a fraction-free simplex, so bounds, integrality tests and
+// infeasibility are decided without rounding.
+
+// ILPResult is the outcome of solveILP for one machine. Exactly one of X and
+// Infeasible is set.
+type ILPResult struct {
+	X          []int64 // presses per button
+	Presses    int64   // sum of X
+	Nodes      int     // branch-and-bound nodes visited
+	Infeasible *Infeasibility
+}
+
+// Certificate proves that no x, integer or not, with Lo <= x <= Hi solves
+// A·x = b. Weighting counter i by Y[i], every such x gives Y·A·x at most
+// the sum over buttons of max(c*Lo[j], c*Hi[j]) with c = (Y·A)[j], and
+// Y·b is larger than that.
+type Certificate struct {
+	Lo, Hi []int64
+	Y      []int64
+}
+
+// Verify checks the certificate against the machine.
+func (c Certificate) Verify(A [][]bool, b []int) bool {
+	var target, reach int64
+	for i := range A {
+		target += c.Y[i] * int64(b[i])
+	}
+	for j := range c.Lo {
+		var w int64
+		for i := range A {
+			if A[i][j] {
+				w += c.Y[i]
+			}
+		}
+		reach += max(w*c.Lo[j], w*c.Hi[j])
+	}
+	return target > reach
+}
+
+// Infeasibility proves that a machine cannot be configured. Branching only
+// splits a box between integer values, so the leaf boxes together hold
+// every integer press vector within the press bounds, and each leaf has a
+// certificate that none of its points works.
+type Infeasibility struct {
+	Leaves []Certificate
+}
+
+// Verify checks every leaf certificate.
+func (p *Infeasibility) Verify(A [][]bool, b []int) bool {
+	for _, c := range p.Leaves {
+		if !c.Verify(A, b) {
+			return false
+		}
+	}
+	return len(p.Leaves) > 0
+}
+
+// pressBounds returns, for every button, the most times it can be pressed
+// without overshooting a counter it raises. A button that raises nothing
+// only adds presses, so its bound is 0.
+func pressBounds(A [][]bool, b []int) []int64 {
+	u := make([]int64, len(A[0]))
+	for j := range u {
+		u[j] = -1
+		for i := range A {
+			if A[i][j] && (u[j] < 0 || int64(b[i]) < u[j]) {
+				u[j] = int64(b[i])
+			}
+		}
+		u[j] = max(u[j], 0)
+	}
+	return u
+}
+
+// solveILP returns a press vector with the fewest presses that reaches the
+// joltages b, or a proof that there is none. It fails when ctx is done or
+// the simplex needs numbers beyond int64.
+func solveILP(ctx context.Context, A [][]bool, b []int) (ILPResult, error) {
+	n := len(A[0])
+	if len(b) != len(A) {
+		panic("row count of A does not match length of b")
+	}
+
+	var res ILPResult
+	var leaves []Certificate
+	best := int64(-1)
+
+	stack := [][2][]int64{{make([]int64, n), pressBounds(A, b)}}
+	for len(stack) > 0 {
+		if err := ctx.Err(); err != nil {
+			return ILPResult{}, err
+		}
+		box := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		lo, hi := box[0], box[1]
+		res.Nodes++
+
+		lp, err := solveLP(A, b, lo, hi)
+		if err != nil {
+			return ILPResult{}, err
+		}
+		if lp.certificate != nil {
+			leaves = append(leaves, *lp.certificate)
+			continue
+		}
+
+		// The objective is integral, so the relaxation's value rounded up
+		// bounds every integer point of the box.
+		if best >= 0 && ceilDiv(lp.num, lp.den) >= best {
+			continue
+		}
+
+		frac := -1
+		for j := range lp.x {
+			if lp.x[j][0]%lp.x[j][1] != 0 {
+				frac = j
+				break
+			}
+		}
+		if frac < 0 {
+			x := make([]int64, n)
+			for j := range x {
+				x[j] = lp.x[j][0] / lp.x[j][1]
+			}
+			best, res.X = lp.num/lp.den, x
+			continue
+		}
+
+		// Split x[frac] = p/q into x <= floor and x >= ceil, pushing the
+		// nearer side last so it is explored first.
+		p, q := lp.x[frac][0], lp.x[frac][1]
+		fl := p / q
+		down := [2][]int64{lo, append([]int64(nil), hi...)}
+		down[1][frac] = fl
+		up := [2][]int64{append([]int64(nil), lo...), hi}
+		up[0][frac] = fl + 1
+		if 2*(p-fl*q) < q {
+			stack = append(stack, up, down)
+		} else {
+			stack = append(stack, down, up)
+		}
+	}
+
+	if res.X == nil {
+		res.Infeasible = &Infeasibility{Leaves: leaves}
+		return res, nil
+	}
+	res.Presses = best
+	return res, nil
+}
+
+// lpSolution is the optimum of one LP relaxation, or a certificate that the
+// box holds no solution at all.
+type lpSolution struct {
+	x           [][2]int64 // x[j] = num/den, den > 0
+	num, den    int64      // objective value num/den
+	certificate *Certificate
+}
+
+// lpTableau is a simplex tableau kept fraction-free: the true entries are
+// t[i][j]/d. Pivoting multiplies by the pivot and divides exactly by the
+// previous d, so every entry stays an integer and nothing is rounded.
+//
+// Columns are the shifted presses y = x - lo, then a slack s per button
+// with y + s = hi - lo, then an artificial variable per counter, then the
+// right-hand side. Rows are the counters, then the bound rows, then the
+// objective, which holds the reduced costs and minus the objective value.
+type lpTableau struct {
+	t        [][]int64
+	d        int64
+	basis    []int
+	overflow bool
+}
+
+// solveLP minimises sum(x) subject to A·x = b and lo <= x <= hi.
+func solveLP(A [][]bool, b []int, lo, hi []int64) (lpSolution, error) {
+	m, n := len(A), len(A[0])
+	rhs := 2*n + m
+	tb := &lpTableau{d: 1, basis: make([]int, m+n)}
+
+	// Counter rows, negated where the shifted target is negative so the
+	// artificial variables start at a feasible value.
+	sign := make([]int64, m)
+	for i := 0; i < m; i++ {
+		row := make([]int64, rhs+1)
+		row[rhs] = int64(b[i])
+		for j := 0; j < n; j++ {
+			if A[i][j] {
+				row[j] = 1
+				row[rhs] -= lo[j]
+			}
+		}
+		sign[i] = 1
+		if row[rhs] < 0 {
+			sign[i] = -1
+			for j := 0; j < 2*n; j++ {
+				row[j] = -row[j]
+			}
+			row[rhs] = -row[rhs]
+		}
+		row[2*n+i] = 1
+		tb.t = append(tb.t, row)
+		tb.basis[i] = 2*n + i
+	}
+	for j := 0; j < n; j++ {
+		row := make([]int64, rhs+1)
+		row[j], row[n+j], row[rhs] = 1, 1, hi[j]-lo[j]
+		tb.t = append(tb.t, row)
+		tb.basis[m+j] = n + j
+	}
+
+	// Phase 1 minimises the sum of the artificial variables.
+	obj := make([]int64, rhs+1)
+	for i := 0; i < m; i++ {
+		for j := 0; j < 2*n; j++ {
+			obj[j] -= tb.t[i][j]
+		}
+		obj[rhs] -= tb.t[i][rhs]
+	}
+	tb.t = append(tb.t, obj)
+	tb.optimise(2*n + m)
+	if tb.overflow {
+		return lpSolution{}, fmt.Errorf("simplex coefficients overflow int64")
+	}
+
+	obj = tb.t[m+n]
+	if obj[rhs] != 0 {
+		// The phase 1 duals weight the counters: y[i]/d = 1 - reduced cost
+		// of artificial i. Scaling by d keeps them integral.
+		cert := Certificate{Lo: lo, Hi: hi, Y: make([]int64, m)}
+		var g int64
+		for i := 0; i < m; i++ {
+			cert.Y[i] = sign[i] * (tb.d - obj[2*n+i])
+			g = gcd(g, cert.Y[i])
+		}
+		for i := range cert.Y {
+			cert.Y[i] /= g
+		}
+		return lpSolution{certificate: &cert}, nil
+	}
+
+	// Artificial variables left in the basis are zero. Pivot each out on
+	// any real column of its row; a row without one is redundant.
+	for i := 0; i < m; i++ {
+		if tb.basis[i] < 2*n {
+			continue
+		}
+		for j := 0; j < 2*n; j++ {
+			if tb.t[i][j] != 0 {
+				if tb.t[i][j] < 0 {
+					for k := range tb.t[i] {
+						tb.t[i][k] = -tb.t[i][k]
+					}
+				}
+				tb.pivot(i, j)
+				break
+			}
+		}
+	}
+
+	// Phase 2 minimises sum(y) over the real columns.
+	for j := range obj {
+		obj[j] = 0
+	}
+	for j := 0; j < n; j++ {
+		obj[j] = tb.d
+	}
+	for i, bj := range tb.basis {
+		if bj >= n {
+			continue
+		}
+		for j := range obj {
+			obj[j] -= tb.t[i][j]
+		}
+	}
+	tb.optimise(2 * n)
+	if tb.overflow {
+		return lpSolution{}, fmt.Errorf("simplex coefficients overflow int64")
+	}
+
+	sol := lpSolution{x: make([][2]int64, n), num: -obj[rhs], den: tb.d}
+	for j := 0; j < n; j++ {
+		sol.x[j] = [2]int64{lo[j] * tb.d, tb.d}
+		sol.num += lo[j] * tb.d
+	}
+	for i, bj := range tb.basis {
+		if bj < n {
+			sol.x[bj][0] += tb.t[i][rhs]
+		}
+	}
+	return sol, nil
+}
+
+// optimise runs the simplex method with Bland's rule, letting only columns
+// below limit enter. Every variable is bounded, so the optimum exists.
+func (tb *lpTableau) optimise(limit int) {
+	obj := tb.t[len(tb.t)-1]
+	rhs := len(obj) - 1
+	for !tb.overflow {
+		c := -1
+		for j := 0; j < limit; j++ {
+			if obj[j] < 0 {
+				c = j
+				break
+			}
+		}
+		if c < 0 {
+			return
+		}
+
+		r := -1
+		for i := 0; i < len(tb.t)-1; i++ {
+			if tb.t[i][c] <= 0 {
+				continue
+			}
+			if r < 0 {
+				r = i
+				continue
+			}
+			// Compare t[i][rhs]/t[i][c] with t[r][rhs]/t[r][c], ties to the
+			// smaller basic variable.
+			cmp := cmpProducts(tb.t[i][rhs], tb.t[r][c], tb.t[r][rhs], tb.t[i][c])
+			if cmp < 0 || (cmp == 0 && tb.basis[i] < tb.basis[r]) {
+				r = i
+			}
+		}
+		tb.pivot(r, c)
+	}
+}
+
+// pivot makes column c basic in row r. t[r][c] must be positive.
+func (tb *lpTableau) pivot(r, c int) {
+	p := tb.t[r][c]
+	for i, row := range tb.t {
+		if i == r {
+			continue
+		}
+		f := row[c]
+		for j := range row {
+			v, ok := fractionFree(row[j], p, f, tb.t[r][j], tb.d)
+			tb.overflow = tb.overflow || !ok
+			row[j] = v
+		}
+	}
+	tb.d = p
+	tb.basis[r] = c
+}
+
+// fractionFree returns (a*p - f*b) / d, which divides exactly.
+func fractionFree(a, p, f, b, d int64) (int64, bool) {
+	if x, ok := mulInt64(a, p); ok {
+		if y, ok := mulInt64(f, b); ok {
+			if v, ok := subInt64(x, y); ok {
+				return v / d, true
+			}
+		}
+	}
+	v := new(big.Int).Mul(big.NewInt(a), big.NewInt(p))
+	v.Sub(v, new(big.Int).Mul(big.NewInt(f), big.NewInt(b)))
+	v.Quo(v, big.NewInt(d))
+	return v.Int64(), v.IsInt64()
+}
+
+// cmpProducts compares a*b with c*d.
+func cmpProducts(a, b, c, d int64) int {
+	if x, ok := mulInt64(a, b); ok {
+		if y, ok := mulInt64(c, d); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	x := new(big.Int).Mul(big.NewInt(a), big.NewInt(b))
+	return x.Cmp(new(big.Int).Mul(big.NewInt(c), big.NewInt(d)))
+}
+
+// ceilDiv returns num/den rounded up for den > 0.
+func ceilDiv(num, den int64) int64 {
+	q := num / den
+	if num%den > 0 {
+		q++
+	}
+	return q
+}
+
+func gcd(a, b int64) int64 {
+	if a < 0 {
+		a = -a
+	}
+	if b < 0 {
+		b = -b
+	}
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
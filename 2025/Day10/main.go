@@ -1,14 +1,15 @@
 package main
 
 import (
-	"bufio"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"math"
 	"os"
-	"strconv"
-	"strings"
+	"os/signal"
+	"runtime"
 	"time"
 )
 
@@ -18,48 +19,6 @@ type Puzzle struct {
 	jolts []int
 }
 
-func createPuzzle(parts []string) Puzzle {
-	var newPuzzle Puzzle
-
-	// [...]
-	innerBrackets := parts[0][1 : len(parts[0])-1]
-	for i := 0; i < len(innerBrackets); i++ {
-		newPuzzle.b = append(newPuzzle.b, innerBrackets[i] == '#')
-	}
-
-	// (...)
-	rows := len(newPuzzle.b)
-	cols := len(parts) - 2
-	var A [][]bool
-	for range rows {
-		var row []bool
-		for range cols {
-			row = append(row, false)
-		}
-		A = append(A, row)
-	}
-	for j := 1; j < len(parts)-1; j++ {
-		innerBrackets := parts[j][1 : len(parts[j])-1]
-		numbers := strings.Split(innerBrackets, ",")
-
-		for _, n := range numbers {
-			i, _ := strconv.Atoi(n)
-			A[i][j-1] = true
-		}
-	}
-	newPuzzle.A = A
-
-	// {...}
-	innerBrackets = parts[len(parts)-1][1 : len(parts[len(parts)-1])-1]
-	jolts := strings.Split(innerBrackets, ",")
-	for _, j := range jolts {
-		i, _ := strconv.Atoi(j)
-		newPuzzle.jolts = append(newPuzzle.jolts, i)
-	}
-
-	return newPuzzle
-}
-
 func readInput(filename string) []Puzzle {
 	file, err := os.Open(filename)
 	if err != nil {
@@ -67,43 +26,13 @@ func readInput(filename string) []Puzzle {
 	}
 	defer file.Close()
 
-	var puzzles []Puzzle
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		parts := strings.Split(line, " ")
-		newPuzzle := createPuzzle(parts)
-		puzzles = append(puzzles, newPuzzle)
-	}
-
-	if err := scanner.Err(); err != nil {
-		log.Fatal(err)
+	puzzles, err := parseMachines(file)
+	if err != nil {
+		log.Fatalf("%s: %v", filename, err)
 	}
-
 	return puzzles
 }
 
-func printMatrix(A [][]float64) {
-	const eps = 1e-9
-
-	for i := range A {
-		for j := 0; j < len(A[0])-1; j++ {
-			v := A[i][j]
-			if math.Abs(v) < eps {
-				v = 0
-			}
-			fmt.Printf("%4.1f ", v)
-		}
-
-		rhs := A[i][len(A[0])-1]
-		if math.Abs(rhs) < eps {
-			rhs = 0
-		}
-		fmt.Printf("| %4.1f\n", rhs)
-	}
-}
-
 func increase(x []bool) {
 	i := len(x) - 1
 	propagation := true
@@ -255,11 +184,55 @@ func extractSolution(A [][]float64, free []Var) []float64 {
 	return x
 }
 
-func search(A [][]float64, level int, free []Var) {
+// enumeration is one brute-force search over the free variables, each from
+// 0 to max, keeping the cheapest solution found. Every machine gets its
+// own, so searches can run side by side.
+type enumeration struct {
+	ctx     context.Context
+	err     error
+	leaves  int
+	max     int
+	bestSum int
+	best    []int64
+}
+
+func newEnumeration(ctx context.Context, jolts []int) *enumeration {
+	return &enumeration{ctx: ctx, max: getHighestJolt(jolts), bestSum: math.MaxInt}
+}
+
+// stopped counts a leaf and reports whether the context has been cancelled,
+// looking at it only every few thousand leaves.
+func (e *enumeration) stopped() bool {
+	if e.err == nil && e.leaves%4096 == 0 {
+		e.err = e.ctx.Err()
+	}
+	e.leaves++
+	return e.err != nil
+}
+
+// record keeps x when it beats the best solution so far.
+func (e *enumeration) record(x []int64) {
+	sum := 0
+	for _, v := range x {
+		sum += int(v)
+	}
+	if sum < e.bestSum {
+		e.bestSum = sum
+		e.best = x
+	}
+}
+
+func (e *enumeration) search(A [][]float64, level int, free []Var) {
+	if e.err != nil {
+		return
+	}
 	if level == len(free) {
+		if e.stopped() {
+			return
+		}
 		x := extractSolution(A, free)
-		sum := 0
-		for _, v := range x {
+		ix := make([]int64, len(x))
+		for i, v := range x {
 			iv := math.Round(v)
 
 			if math.Abs(v-iv) > eps {
@@ -270,21 +243,39 @@ func search(A [][]float64, level int, free []Var) {
 				return // truly negative
 			}
 
-			sum += int(iv)
-		}
-
-		if sum < bestSum {
-			bestSum = sum
-			bestSolution = append([]float64{}, x...)
+			ix[i] = int64(iv)
 		}
+		e.record(ix)
 		return
 	}
 
-	for v := 0; v <= MAX; v++ {
+	for v := 0; v <= e.max; v++ {
 		next := make([]Var, len(free))
 		copy(next, free)
 		next[level].val = float64(v)
-		search(A, level+1, next)
+		e.search(A, level+1, next)
+	}
+}
+
+// searchExact is search on the exact system: the free variables run from 0
+// to max and the pivot variables follow in integer arithmetic.
+func (e *enumeration) searchExact(s *exactSystem, level int, vals []int64) {
+	if e.err != nil {
+		return
+	}
+	if level == len(vals) {
+		if e.stopped() {
+			return
+		}
+		if x, ok := s.solve(vals); ok {
+			e.record(x)
+		}
+		return
+	}
+
+	for v := 0; v <= e.max; v++ {
+		vals[level] = int64(v)
+		e.searchExact(s, level+1, vals)
 	}
 }
 
@@ -299,13 +290,13 @@ func getHighestJolt(jolts []int) int {
 	return highest
 }
 
-var MAX int
-var bestSum int
-var bestSolution []float64
-
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
 	inputFile := flag.String("file", "input.txt", "path to the input file")
+	solver := flag.String("solver", "ilp", "part 2 solver: ilp (branch and bound), exact or float (enumerate the free variables)")
+	workers := flag.Int("workers", runtime.NumCPU(), "machines to solve at once in part 2")
+	timeout := flag.Duration("timeout", time.Minute, "give up on a part 2 machine after this long, 0 for never")
+	verbose := flag.Bool("v", false, "log the presses of every part 2 machine")
 	flag.Parse()
 
 	start := time.Now()
@@ -314,43 +305,48 @@ func main() {
 	// === Part 1 ===
 	minCounts := 0
 	puzzles := readInput(*inputFile)
-	for _, puzzle := range puzzles {
-		minCount := solve(puzzle.A, puzzle.b)
-		minCounts += minCount
+	for i, puzzle := range puzzles {
+		pressed, err := solveLights(puzzle.A, puzzle.b)
+		if err != nil {
+			log.Fatalf("machine %d: %v", i+1, err)
+		}
+		minCounts += countTrues(pressed)
 	}
 	log.Println("PART 1: Total:", minCounts)
 
 	// === Part 2 ===
 	log.Println("PART 2...")
-	total := 0
+	solve, err := machineSolverNamed(*solver)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	for i, puzzle := range puzzles {
-		MAX = getHighestJolt(puzzle.jolts)
-		bestSum = math.MaxInt
-		bestSolution = make([]float64, 0)
-
-		log.Printf("%d / %d", i+1, len(puzzles))
-		log.Println("MAX:", MAX)
-
-		augmented := buildAugmentedMatrix(puzzle.A, puzzle.jolts)
-		printMatrix(augmented)
-		fmt.Println("---")
-		gA := rref(augmented)
-		printMatrix(gA)
-		freeVariables := getFreeVariables(gA)
-		log.Printf("Free variables: %v", freeVariables)
-		free := getFreeVariables(gA)
-		search(gA, 0, free)
-		minimum := int(bestSum)
-		log.Printf("The minimum: %d\n", minimum)
-		log.Println("Solution:", bestSolution)
-
-		if bestSum > 5000 {
-			log.Println("=========== NOOOOOOOOOOOO ===========")
-			break
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	solutions, err := solveAll(ctx, puzzles, solve, poolOptions{
+		workers: *workers,
+		timeout: *timeout,
+		progress: func(done, total int) {
+			fmt.Fprintf(os.Stderr, "\r%d / %d machines solved", done, total)
+		},
+	})
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		var infeasible *infeasibleError
+		if errors.As(err, &infeasible) {
+			for _, c := range infeasible.proof.Leaves {
+				log.Printf("presses %v..%v: counter weights %v", c.Lo, c.Hi, c.Y)
+			}
 		}
+		log.Fatal(err)
+	}
 
-		total += int(bestSum)
+	total := int64(0)
+	for i, sol := range solutions {
+		if *verbose {
+			log.Printf("machine %d: %d presses %v", i+1, sol.presses, sol.x)
+		}
+		total += sol.presses
 	}
 
 	log.Println("In total:", total)
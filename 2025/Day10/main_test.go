@@ -1,6 +1,14 @@
 package main
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestIncrease(t *testing.T) {
 	tests := []struct {
@@ -44,3 +52,486 @@ func TestIncrease(t *testing.T) {
 		}
 	}
 }
+
+// bestExact and bestFloat run the two part 2 searches on one machine.
+func bestExact(t *testing.T, p Puzzle) int {
+	t.Helper()
+	sys, err := newExactSystem(p.A, p.jolts)
+	if err != nil {
+		t.Fatalf("newExactSystem: %v", err)
+	}
+	e := newEnumeration(context.Background(), p.jolts)
+	if sys.consistent {
+		e.searchExact(sys, 0, make([]int64, len(sys.free)))
+	}
+	return e.bestSum
+}
+
+func bestFloat(p Puzzle) int {
+	e := newEnumeration(context.Background(), p.jolts)
+	gA := rref(buildAugmentedMatrix(p.A, p.jolts))
+	e.search(gA, 0, getFreeVariables(gA))
+	return e.bestSum
+}
+
+func TestExactMatchesFloat(t *testing.T) {
+	puzzles := readInput("test.txt")
+	// A few machines from the real input with at most two free variables
+	// keep this fast.
+	for _, p := range readInput("input.txt") {
+		if sys, _ := newExactSystem(p.A, p.jolts); sys != nil && len(sys.free) <= 2 {
+			puzzles = append(puzzles, p)
+		}
+	}
+
+	for i, p := range puzzles {
+		if got, want := bestExact(t, p), bestFloat(p); got != want {
+			t.Errorf("machine %d: exact minimum %d, float minimum %d", i+1, got, want)
+		}
+	}
+}
+
+func TestExactSystemExample(t *testing.T) {
+	want := []int{10, 12, 11}
+	for i, p := range readInput("test.txt") {
+		if got := bestExact(t, p); got != want[i] {
+			t.Errorf("machine %d: minimum = %d, want %d", i+1, got, want[i])
+		}
+	}
+}
+
+func TestExactSystemFractions(t *testing.T) {
+	// Every pair of the three counters shares a button, so each button
+	// would have to be pressed half a time.
+	A := [][]bool{
+		{true, true, false},
+		{false, true, true},
+		{true, false, true},
+	}
+	sys, err := newExactSystem(A, []int{1, 1, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sys.free) != 0 || !sys.consistent {
+		t.Fatalf("free = %v, consistent = %v; want no free variables, consistent", sys.free, sys.consistent)
+	}
+	if x, ok := sys.solve(nil); ok {
+		t.Errorf("solve = %v; want no integer solution", x)
+	}
+
+	sys, _ = newExactSystem(A, []int{2, 2, 2})
+	if x, ok := sys.solve(nil); !ok || !slices.Equal(x, []int64{1, 1, 1}) {
+		t.Errorf("solve = %v, %v; want [1 1 1], true", x, ok)
+	}
+}
+
+func TestExactSystemInconsistent(t *testing.T) {
+	// Two counters driven by the same single button cannot differ.
+	sys, err := newExactSystem([][]bool{{true}, {true}}, []int{3, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sys.consistent {
+		t.Errorf("consistent = true; want false")
+	}
+}
+
+func TestExactSystemLargeJoltage(t *testing.T) {
+	// 2^53+1 is not a float64, so the float path cannot get this right.
+	const big = 1<<53 + 1
+	A := [][]bool{{true, true}, {false, true}}
+	sys, err := newExactSystem(A, []int{big, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x, ok := sys.solve(nil); !ok || !slices.Equal(x, []int64{big - 1, 1}) {
+		t.Errorf("solve = %v, %v; want [%d 1], true", x, ok, int64(big-1))
+	}
+}
+
+func TestSolveILPMatchesSearch(t *testing.T) {
+	puzzles := readInput("test.txt")
+	for _, p := range readInput("input.txt") {
+		if sys, _ := newExactSystem(p.A, p.jolts); sys != nil && len(sys.free) <= 2 {
+			puzzles = append(puzzles, p)
+		}
+	}
+
+	for i, p := range puzzles {
+		res, err := solveILP(context.Background(), p.A, p.jolts)
+		if err != nil {
+			t.Fatalf("machine %d: %v", i+1, err)
+		}
+		if res.Infeasible != nil {
+			t.Errorf("machine %d: infeasible; want a solution", i+1)
+			continue
+		}
+		if got, want := int(res.Presses), bestExact(t, p); got != want {
+			t.Errorf("machine %d: presses = %d; want %d", i+1, got, want)
+		}
+		if !reaches(p.A, res.X, p.jolts) {
+			t.Errorf("machine %d: presses %v do not reach %v", i+1, res.X, p.jolts)
+		}
+	}
+}
+
+// reaches reports whether pressing the buttons x times, x >= 0, sets the
+// counters to exactly b.
+func reaches(A [][]bool, x []int64, b []int) bool {
+	for i := range A {
+		var sum int64
+		for j, v := range x {
+			if v < 0 {
+				return false
+			}
+			if A[i][j] {
+				sum += v
+			}
+		}
+		if sum != int64(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSolveILPInfeasible(t *testing.T) {
+	tests := []struct {
+		name string
+		A    [][]bool
+		b    []int
+	}{
+		// Already the relaxation has no solution: one button drives both.
+		{"relaxation", [][]bool{{true}, {true}}, []int{3, 4}},
+		// Only half presses would do, so branching has to rule out every
+		// integer vector.
+		{"fractional", [][]bool{
+			{true, true, false},
+			{false, true, true},
+			{true, false, true},
+		}, []int{1, 1, 1}},
+		// The counters differ by more than the buttons can make up.
+		{"bounds", [][]bool{{true, true}, {true, false}}, []int{2, 5}},
+	}
+
+	for _, tt := range tests {
+		res, err := solveILP(context.Background(), tt.A, tt.b)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if res.Infeasible == nil {
+			t.Errorf("%s: solution %v; want infeasible", tt.name, res.X)
+			continue
+		}
+		if !res.Infeasible.Verify(tt.A, tt.b) {
+			t.Errorf("%s: proof %+v does not verify", tt.name, res.Infeasible.Leaves)
+		}
+	}
+}
+
+func TestPressBounds(t *testing.T) {
+	A := [][]bool{
+		{true, false, true, false},
+		{true, true, false, false},
+	}
+	got := pressBounds(A, []int{7, 3})
+	want := []int64{3, 3, 7, 0}
+	if !slices.Equal(got, want) {
+		t.Errorf("pressBounds = %v; want %v", got, want)
+	}
+}
+
+func TestSolveLightsMatchesBruteForce(t *testing.T) {
+	puzzles := append(readInput("test.txt"), readInput("input.txt")...)
+	for i, p := range puzzles {
+		pressed, err := solveLights(p.A, p.b)
+		if err != nil {
+			t.Errorf("machine %d: %v", i+1, err)
+			continue
+		}
+		if !check(p.A, p.b, pressed) {
+			t.Errorf("machine %d: pressing %v does not switch on %v", i+1, pressed, p.b)
+		}
+		if got, want := countTrues(pressed), solve(p.A, p.b); got != want {
+			t.Errorf("machine %d: presses = %d; want %d", i+1, got, want)
+		}
+	}
+}
+
+func TestSolveLightsManyButtons(t *testing.T) {
+	// 70 lights, each with a button of its own, and ten more buttons that
+	// each toggle a pair of lights, (0,1), (2,3) and so on. Lights 0 to 5
+	// are on, which the pair buttons manage in three presses.
+	const lights, pairs = 70, 10
+	A := make([][]bool, lights)
+	for i := range A {
+		A[i] = make([]bool, lights+pairs)
+		A[i][i] = true
+		if i < 2*pairs {
+			A[i][lights+i/2] = true
+		}
+	}
+	b := make([]bool, lights)
+	for i := 0; i < 6; i++ {
+		b[i] = true
+	}
+
+	pressed, err := solveLights(A, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !check(A, b, pressed) {
+		t.Errorf("pressed buttons do not switch on lights 0 to 5")
+	}
+	if got := countTrues(pressed); got != 3 {
+		t.Errorf("presses = %d; want 3", got)
+	}
+}
+
+func TestSolveLightsInconsistent(t *testing.T) {
+	// Both lights share their only button, so one cannot be on alone.
+	A := [][]bool{{true, false}, {true, false}}
+	if pressed, err := solveLights(A, []bool{true, false}); !errors.Is(err, errLightsUnreachable) {
+		t.Errorf("solveLights = %v, %v; want errLightsUnreachable", pressed, err)
+	}
+	if pressed, err := solveLights(A, []bool{false, false}); err != nil || countTrues(pressed) != 0 {
+		t.Errorf("solveLights = %v, %v; want no presses", pressed, err)
+	}
+}
+
+func TestSolveLightsManyFreeButtons(t *testing.T) {
+	// 70 copies of one button: 69 free buttons, far too many to walk the
+	// null space, but a single light pattern to search.
+	line := "[#]" + strings.Repeat(" (0)", 70) + " {70}"
+	p, err := parseMachine(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pressed, err := solveLights(p.A, p.b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := countTrues(pressed); got != 1 || !check(p.A, p.b, pressed) {
+		t.Errorf("pressed %v; want a single button", pressed)
+	}
+
+	// Four lights and every one of the 15 non-empty buttons: more free
+	// buttons than pivots, checked against trying every combination.
+	A := make([][]bool, 4)
+	for i := range A {
+		A[i] = make([]bool, 15)
+		for j := range A[i] {
+			A[i][j] = (j+1)&(1<<i) != 0
+		}
+	}
+	for pattern := 0; pattern < 16; pattern++ {
+		b := make([]bool, 4)
+		for i := range b {
+			b[i] = pattern&(1<<i) != 0
+		}
+		pressed, err := solveLights(A, b)
+		if err != nil {
+			t.Fatalf("lights %v: %v", b, err)
+		}
+		if !check(A, b, pressed) {
+			t.Errorf("lights %v: pressing %v does not switch them on", b, pressed)
+		}
+		if got, want := countTrues(pressed), solve(A, b); got != want {
+			t.Errorf("lights %v: presses = %d; want %d", b, got, want)
+		}
+	}
+}
+
+func TestSolveLightsManyPivotsAndFreeButtons(t *testing.T) {
+	// 22 lights with a button each plus 24 buttons toggling neighbours:
+	// more pivots than the pattern search takes, but few enough free
+	// buttons for the null space.
+	const lights, extra = 22, 24
+	A := make([][]bool, lights)
+	for i := range A {
+		A[i] = make([]bool, lights+extra)
+		A[i][i] = true
+	}
+	for k := 0; k < extra; k++ {
+		A[k%lights][lights+k] = true
+		A[(k+1)%lights][lights+k] = true
+	}
+	b := make([]bool, lights)
+	b[0], b[1] = true, true
+
+	pressed, err := solveLights(A, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !check(A, b, pressed) {
+		t.Errorf("pressed buttons do not switch on lights 0 and 1")
+	}
+	if got := countTrues(pressed); got != 1 {
+		t.Errorf("presses = %d; want 1", got)
+	}
+}
+
+func TestSolveLightsTooLarge(t *testing.T) {
+	// 21 lights with a button each plus 27 buttons toggling neighbours:
+	// rank 21 and 27 free buttons, both beyond the search limits.
+	const lights, extra = 21, 27
+	A := make([][]bool, lights)
+	for i := range A {
+		A[i] = make([]bool, lights+extra)
+		A[i][i] = true
+	}
+	for k := 0; k < extra; k++ {
+		A[k%lights][lights+k] = true
+		A[(k+1)%lights][lights+k] = true
+	}
+	_, err := solveLights(A, make([]bool, lights))
+	if err == nil || errors.Is(err, errLightsUnreachable) {
+		t.Errorf("solveLights error = %v; want too many to search", err)
+	}
+}
+
+func TestParseMachineWhitespace(t *testing.T) {
+	want, err := parseMachine("[.##.] (3) (1,3) (2) (2,3) (0,2) (0,1) {3,5,4,7}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := parseMachine("  [ .# #. ]\t(3)(1 , 3)  ( 2 ) (2,3) (0,2)\t(0,1){ 3, 5,4 ,7 }  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseMachine = %+v; want %+v", got, want)
+	}
+}
+
+func TestParseMachineErrors(t *testing.T) {
+	tests := []struct {
+		line string
+		col  int
+		msg  string
+	}{
+		{"(0) {1}", 1, "expected '['"},
+		{"[.#) (0) {1,1}", 4, `close '[' at column 1, found ")"`},
+		{"[.#] (0,1} {1,1}", 10, `close "(" at column 6, found "}"`},
+		{"[.#] (0,2) {1,1}", 9, "light 2, but the machine has 2 lights"},
+		{"[.#] (1,1) {1,1}", 9, "light 1 twice"},
+		{"[.#] () {1,1}", 7, "expected a number"},
+		{"[.#] {1,1}", 6, "expected '(' to start a button"},
+		{"[.#] (0) {1,1,1}", 10, "3 joltages for 2 lights"},
+		{"[.#] (0) {1,1} (1)", 16, `unexpected "("`},
+		{"[.#] (0) {1,1", 14, "found end of line"},
+		{"[.x] (0) {1,1}", 3, `unexpected character 'x'`},
+		{"[.#] (99999999999999999999) {1,1}", 7, "out of range"},
+		{"[] (0) {}", 1, "no lights"},
+	}
+
+	for _, tt := range tests {
+		_, err := parseMachine(tt.line)
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("parseMachine(%q) = %v; want a ParseError", tt.line, err)
+			continue
+		}
+		if perr.Col != tt.col || !strings.Contains(perr.Msg, tt.msg) {
+			t.Errorf("parseMachine(%q): column %d, %q; want column %d, %q", tt.line, perr.Col, perr.Msg, tt.col, tt.msg)
+		}
+	}
+}
+
+func TestParseMachinesLineNumbers(t *testing.T) {
+	input := "[.#] (0) (1) {1,2}\n\n[##] (0,1) (2) {3,3}\n"
+	_, err := parseMachines(strings.NewReader(input))
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("parseMachines = %v; want a ParseError", err)
+	}
+	if got, want := perr.Error(), "line 3, column 13: button toggles light 2, but the machine has 2 lights"; got != want {
+		t.Errorf("error = %q; want %q", got, want)
+	}
+}
+
+func TestSolveAllInOrder(t *testing.T) {
+	puzzles := readInput("input.txt")[:40]
+	var want []solution
+	for _, p := range puzzles {
+		sol, err := solveWithILP(context.Background(), p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, sol)
+	}
+
+	var calls []int
+	got, err := solveAll(context.Background(), puzzles, solveWithILP, poolOptions{
+		workers:  4,
+		progress: func(done, total int) { calls = append(calls, done) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("solveAll returned solutions out of order")
+	}
+	if len(calls) != len(puzzles) || calls[len(calls)-1] != len(puzzles) {
+		t.Errorf("progress called with %v; want 1 to %d", calls, len(puzzles))
+	}
+}
+
+func TestSolveAllTimeout(t *testing.T) {
+	puzzles := readInput("test.txt")
+	// The second machine never finishes on its own.
+	slow := func(ctx context.Context, p Puzzle) (solution, error) {
+		if len(p.jolts) == len(puzzles[1].jolts) && slices.Equal(p.jolts, puzzles[1].jolts) {
+			<-ctx.Done()
+			return solution{}, ctx.Err()
+		}
+		return solveWithILP(ctx, p)
+	}
+
+	_, err := solveAll(context.Background(), puzzles, slow, poolOptions{workers: 2, timeout: 20 * time.Millisecond})
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.HasPrefix(err.Error(), "machine 2:") {
+		t.Errorf("solveAll = %v; want machine 2 to time out", err)
+	}
+}
+
+func TestSolveAllFirstFailureCancels(t *testing.T) {
+	puzzles := readInput("test.txt")
+	// Machine 3 fails at once; the others wait for cancellation and must
+	// not be blamed for it.
+	solver := func(ctx context.Context, p Puzzle) (solution, error) {
+		if slices.Equal(p.jolts, puzzles[2].jolts) {
+			return solution{}, errNoSolution
+		}
+		<-ctx.Done()
+		return solution{}, ctx.Err()
+	}
+
+	_, err := solveAll(context.Background(), puzzles, solver, poolOptions{workers: 3})
+	if !errors.Is(err, errNoSolution) || !strings.HasPrefix(err.Error(), "machine 3:") {
+		t.Errorf("solveAll = %v; want machine 3 to fail", err)
+	}
+}
+
+func TestSolveAllCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := solveAll(ctx, readInput("input.txt"), solveWithFloat, poolOptions{workers: 2})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("solveAll = %v; want context.Canceled", err)
+	}
+}
+
+func TestSolveWithILPInfeasible(t *testing.T) {
+	p, err := parseMachine("[##] (0) (0,1) {3,4}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = solveWithILP(context.Background(), p)
+	var infeasible *infeasibleError
+	if !errors.As(err, &infeasible) || !errors.Is(err, errNoSolution) {
+		t.Fatalf("solveWithILP = %v; want an infeasibleError", err)
+	}
+	if !infeasible.proof.Verify(p.A, p.jolts) {
+		t.Errorf("proof does not verify")
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"unicode"
+)
+
+// A machine line reads
+//
+//	[.##.] (3) (1,3) (2) (2,3) (0,2) (0,1) {3,5,4,7}
+//
+// the indicator lights, then the buttons with the lights they toggle, then
+// the joltage of every counter. Whitespace may appear anywhere between
+// tokens, including inside the brackets.
+
+// ParseError is a malformed machine line. Line and Col are 1-based; Col
+// counts characters.
+type ParseError struct {
+	Line, Col int
+	Msg       string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Col, e.Msg)
+}
+
+// token is one lexeme of a machine line: a bracket, a comma, a number or a
+// run of lights.
+type token struct {
+	kind rune // one of []{}(), ',', 'n' for a number, 'l' for lights, 0 at the end
+	text string
+	col  int
+}
+
+// tokenize splits line into tokens, skipping whitespace.
+func tokenize(line string) ([]token, error) {
+	var toks []token
+	runes := []rune(line)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		col := i + 1
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '[' || r == ']' || r == '(' || r == ')' || r == '{' || r == '}' || r == ',':
+			toks = append(toks, token{kind: r, text: string(r), col: col})
+			i++
+		case r >= '0' && r <= '9':
+			j := i
+			for j < len(runes) && runes[j] >= '0' && runes[j] <= '9' {
+				j++
+			}
+			toks = append(toks, token{kind: 'n', text: string(runes[i:j]), col: col})
+			i = j
+		case r == '.' || r == '#':
+			j := i
+			for j < len(runes) && (runes[j] == '.' || runes[j] == '#') {
+				j++
+			}
+			toks = append(toks, token{kind: 'l', text: string(runes[i:j]), col: col})
+			i = j
+		default:
+			return nil, &ParseError{Col: col, Msg: fmt.Sprintf("unexpected character %q", r)}
+		}
+	}
+	return append(toks, token{col: len(runes) + 1}), nil
+}
+
+// machineParser reads the tokens of one line.
+type machineParser struct {
+	toks []token
+	pos  int
+}
+
+func (p *machineParser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *machineParser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != 0 {
+		p.pos++
+	}
+	return t
+}
+
+func errorAt(t token, format string, args ...any) error {
+	return &ParseError{Col: t.col, Msg: fmt.Sprintf(format, args...)}
+}
+
+// describe names a token for error messages.
+func describe(t token) string {
+	if t.kind == 0 {
+		return "end of line"
+	}
+	return strconv.Quote(t.text)
+}
+
+// closing returns the bracket that closes open.
+func closing(open rune) rune {
+	switch open {
+	case '[':
+		return ']'
+	case '(':
+		return ')'
+	}
+	return '}'
+}
+
+// numbers reads a comma-separated list of non-negative integers up to the
+// bracket that closes open, which it consumes.
+func (p *machineParser) numbers(open token) ([]int, []token, error) {
+	var vals []int
+	var toks []token
+	for {
+		t := p.next()
+		if t.kind != 'n' {
+			return nil, nil, errorAt(t, "expected a number in %q at column %d, found %s", open.text, open.col, describe(t))
+		}
+		v, err := strconv.Atoi(t.text)
+		if err != nil {
+			return nil, nil, errorAt(t, "number %s is out of range", t.text)
+		}
+		vals = append(vals, v)
+		toks = append(toks, t)
+
+		switch sep := p.next(); sep.kind {
+		case ',':
+		case closing(open.kind):
+			return vals, toks, nil
+		default:
+			return nil, nil, errorAt(sep, "expected ',' or %q to close %q at column %d, found %s",
+				closing(open.kind), open.text, open.col, describe(sep))
+		}
+	}
+}
+
+// parseMachine parses one machine line. Errors are *ParseError with the
+// column set.
+func parseMachine(line string) (Puzzle, error) {
+	toks, err := tokenize(line)
+	if err != nil {
+		return Puzzle{}, err
+	}
+	p := &machineParser{toks: toks}
+	var puzzle Puzzle
+
+	// [...]
+	open := p.next()
+	if open.kind != '[' {
+		return Puzzle{}, errorAt(open, "expected '[' to start the lights, found %s", describe(open))
+	}
+	for p.peek().kind == 'l' {
+		for _, c := range p.next().text {
+			puzzle.b = append(puzzle.b, c == '#')
+		}
+	}
+	if t := p.next(); t.kind != ']' {
+		return Puzzle{}, errorAt(t, "expected '.', '#' or ']' to close '[' at column %d, found %s", open.col, describe(t))
+	}
+	lights := len(puzzle.b)
+	if lights == 0 {
+		return Puzzle{}, errorAt(open, "machine has no lights")
+	}
+
+	// (...)
+	var buttons [][]int
+	for p.peek().kind == '(' {
+		open := p.next()
+		vals, toks, err := p.numbers(open)
+		if err != nil {
+			return Puzzle{}, err
+		}
+		seen := make([]bool, lights)
+		for k, v := range vals {
+			if v >= lights {
+				return Puzzle{}, errorAt(toks[k], "button toggles light %d, but the machine has %d lights", v, lights)
+			}
+			if seen[v] {
+				return Puzzle{}, errorAt(toks[k], "button lists light %d twice", v)
+			}
+			seen[v] = true
+		}
+		buttons = append(buttons, vals)
+	}
+	if len(buttons) == 0 {
+		t := p.peek()
+		return Puzzle{}, errorAt(t, "expected '(' to start a button, found %s", describe(t))
+	}
+
+	puzzle.A = make([][]bool, lights)
+	for i := range puzzle.A {
+		puzzle.A[i] = make([]bool, len(buttons))
+	}
+	for j, button := range buttons {
+		for _, i := range button {
+			puzzle.A[i][j] = true
+		}
+	}
+
+	// {...}
+	open = p.next()
+	if open.kind != '{' {
+		return Puzzle{}, errorAt(open, "expected '(' or '{' after the buttons, found %s", describe(open))
+	}
+	if puzzle.jolts, _, err = p.numbers(open); err != nil {
+		return Puzzle{}, err
+	}
+	if len(puzzle.jolts) != lights {
+		return Puzzle{}, errorAt(open, "%d joltages for %d lights", len(puzzle.jolts), lights)
+	}
+
+	if t := p.next(); t.kind != 0 {
+		return Puzzle{}, errorAt(t, "unexpected %s after the joltages", describe(t))
+	}
+	return puzzle, nil
+}
+
+// parseMachines parses one machine per line, skipping blank lines.
+func parseMachines(r io.Reader) ([]Puzzle, error) {
+	var puzzles []Puzzle
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		blank := true
+		for _, c := range line {
+			blank = blank && unicode.IsSpace(c)
+		}
+		if blank {
+			continue
+		}
+
+		puzzle, err := parseMachine(line)
+		if err != nil {
+			err.(*ParseError).Line = lineNo
+			return nil, err
+		}
+		puzzles = append(puzzles, puzzle)
+	}
+	return puzzles, scanner.Err()
+}
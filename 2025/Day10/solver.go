@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// solution is the part 2 answer for one machine.
+type solution struct {
+	presses int64
+	x       []int64 // presses per button
+}
+
+// machineSolver solves part 2 for one machine. It keeps all of its state
+// in the call, so machines can be solved concurrently, and it gives up with
+// the context's error once ctx is done.
+type machineSolver func(ctx context.Context, p Puzzle) (solution, error)
+
+// errNoSolution means no press vector reaches the joltages.
+var errNoSolution = errors.New("no press vector reaches the joltages")
+
+// infeasibleError is errNoSolution with the branch-and-bound proof.
+type infeasibleError struct {
+	proof *Infeasibility
+}
+
+func (e *infeasibleError) Error() string {
+	return fmt.Sprintf("%v (proof with %d certificates)", errNoSolution, len(e.proof.Leaves))
+}
+
+func (e *infeasibleError) Unwrap() error {
+	return errNoSolution
+}
+
+// machineSolverNamed returns the solver selected by the -solver flag.
+func machineSolverNamed(name string) (machineSolver, error) {
+	switch name {
+	case "ilp":
+		return solveWithILP, nil
+	case "exact":
+		return solveWithExact, nil
+	case "float":
+		return solveWithFloat, nil
+	}
+	return nil, fmt.Errorf("unknown solver %q", name)
+}
+
+func solveWithILP(ctx context.Context, p Puzzle) (solution, error) {
+	res, err := solveILP(ctx, p.A, p.jolts)
+	if err != nil {
+		return solution{}, err
+	}
+	if res.Infeasible != nil {
+		return solution{}, &infeasibleError{proof: res.Infeasible}
+	}
+	return solution{presses: res.Presses, x: res.X}, nil
+}
+
+func solveWithExact(ctx context.Context, p Puzzle) (solution, error) {
+	sys, err := newExactSystem(p.A, p.jolts)
+	if err != nil {
+		return solution{}, err
+	}
+	e := newEnumeration(ctx, p.jolts)
+	if sys.consistent {
+		e.searchExact(sys, 0, make([]int64, len(sys.free)))
+	}
+	return e.result()
+}
+
+func solveWithFloat(ctx context.Context, p Puzzle) (solution, error) {
+	e := newEnumeration(ctx, p.jolts)
+	gA := rref(buildAugmentedMatrix(p.A, p.jolts))
+	e.search(gA, 0, getFreeVariables(gA))
+	return e.result()
+}
+
+// result returns the best solution once the search is over.
+func (e *enumeration) result() (solution, error) {
+	switch {
+	case e.err != nil:
+		return solution{}, e.err
+	case e.best == nil:
+		return solution{}, errNoSolution
+	}
+	return solution{presses: int64(e.bestSum), x: e.best}, nil
+}
+
+// poolOptions configures solveAll.
+type poolOptions struct {
+	workers int           // machines solved at once, at least 1
+	timeout time.Duration // per machine, 0 for none
+	// progress, if set, is called after every solved machine with the
+	// number solved so far.
+	progress func(done, total int)
+}
+
+// solveAll solves every machine on a pool of workers and returns the
+// solutions in input order. The first failure cancels the machines still
+// running and is returned, wrapped with its machine number; a machine that
+// was only cancelled because of it is not reported instead.
+func solveAll(ctx context.Context, puzzles []Puzzle, solve machineSolver, opts poolOptions) ([]solution, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type outcome struct {
+		i   int
+		sol solution
+		err error
+	}
+	jobs := make(chan int)
+	outcomes := make(chan outcome)
+
+	var wg sync.WaitGroup
+	for range max(1, opts.workers) {
+		wg.Go(func() {
+			for i := range jobs {
+				mctx, mcancel := ctx, context.CancelFunc(func() {})
+				if opts.timeout > 0 {
+					mctx, mcancel = context.WithTimeout(ctx, opts.timeout)
+				}
+				sol, err := solve(mctx, puzzles[i])
+				mcancel()
+				outcomes <- outcome{i, sol, err}
+			}
+		})
+	}
+	go func() {
+		defer close(jobs)
+		for i := range puzzles {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(outcomes)
+	}()
+
+	solutions := make([]solution, len(puzzles))
+	errs := make([]error, len(puzzles))
+	done := 0
+	for o := range outcomes {
+		if o.err != nil {
+			errs[o.i] = o.err
+			cancel()
+			continue
+		}
+		solutions[o.i] = o.sol
+		done++
+		if opts.progress != nil {
+			opts.progress(done, len(puzzles))
+		}
+	}
+
+	var first error
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		err = fmt.Errorf("machine %d: %w", i+1, err)
+		if !errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		if first == nil {
+			first = err
+		}
+	}
+	if first != nil {
+		return nil, first
+	}
+	if done < len(puzzles) {
+		return nil, ctx.Err()
+	}
+	return solutions, nil
+}
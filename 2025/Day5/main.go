@@ -4,6 +4,8 @@ import (
 	"bufio"
 	"fmt"
 	"log"
+	"math"
+	"math/big"
 	"os"
 	"sort"
 	"strconv"
@@ -12,11 +14,38 @@ import (
 )
 
 type IntRange struct {
-	Start int
-	End   int
+	Start int64
+	End   int64
 }
 
-func readInput(filename string) ([]IntRange, []int) {
+// Len returns the number of IDs in the range. A range spanning the whole
+// int64 domain holds 2^64 IDs, which does not fit in any machine integer.
+func (r IntRange) Len() *big.Int {
+	n := new(big.Int).Sub(big.NewInt(r.End), big.NewInt(r.Start))
+	return n.Add(n, big.NewInt(1))
+}
+
+// parseRange splits a "start-end" line. Either bound may be negative, so the
+// separator is the first '-' that is not a leading sign.
+func parseRange(line string) (IntRange, bool) {
+	if len(line) < 2 {
+		return IntRange{}, false
+	}
+	sep := strings.IndexByte(line[1:], '-')
+	if sep < 0 {
+		return IntRange{}, false
+	}
+	sep++
+
+	start, err1 := strconv.ParseInt(line[:sep], 10, 64)
+	end, err2 := strconv.ParseInt(line[sep+1:], 10, 64)
+	if err1 != nil || err2 != nil {
+		log.Fatalf("invalid range numbers: %q", line)
+	}
+	return IntRange{Start: start, End: end}, true
+}
+
+func readInput(filename string) ([]IntRange, []int64) {
 	f, err := os.Open(filename)
 	if err != nil {
 		log.Fatalf("failed to open file: %v", err)
@@ -25,7 +54,7 @@ func readInput(filename string) ([]IntRange, []int) {
 
 	scanner := bufio.NewScanner(f)
 	var ranges []IntRange
-	var ids []int
+	var ids []int64
 
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
@@ -33,21 +62,12 @@ func readInput(filename string) ([]IntRange, []int) {
 			continue
 		}
 
-		if strings.Contains(line, "-") {
-			parts := strings.Split(line, "-")
-			if len(parts) != 2 {
-				log.Fatalf("invalid range line: %q", line)
-			}
-			start, err1 := strconv.Atoi(parts[0])
-			end, err2 := strconv.Atoi(parts[1])
-			if err1 != nil || err2 != nil {
-				log.Fatalf("invalid range numbers: %q", line)
-			}
-			ranges = append(ranges, IntRange{Start: start, End: end})
+		if r, ok := parseRange(line); ok {
+			ranges = append(ranges, r)
 			continue
 		}
 
-		id, err := strconv.Atoi(line)
+		id, err := strconv.ParseInt(line, 10, 64)
 		if err != nil {
 			log.Fatalf("invalid ingredient ID: %q", line)
 		}
@@ -61,7 +81,7 @@ func readInput(filename string) ([]IntRange, []int) {
 	return ranges, ids
 }
 
-func isIngredientFresh(ranges []IntRange, id int) bool {
+func isIngredientFresh(ranges []IntRange, id int64) bool {
 	for _, r := range ranges {
 		if id >= r.Start && id <= r.End {
 			return true
@@ -70,7 +90,20 @@ func isIngredientFresh(ranges []IntRange, id int) bool {
 	return false
 }
 
+// touches reports whether r overlaps or directly follows last. last.End+1 is
+// only evaluated when it cannot overflow.
+func touches(last, r IntRange) bool {
+	if r.Start <= last.End {
+		return true
+	}
+	return last.End < math.MaxInt64 && r.Start == last.End+1
+}
+
 func mergeRanges(ranges []IntRange) []IntRange {
+	if len(ranges) == 0 {
+		return nil
+	}
+
 	sort.Slice(ranges, func(i, j int) bool {
 		return ranges[i].Start < ranges[j].Start
 	})
@@ -79,7 +112,7 @@ func mergeRanges(ranges []IntRange) []IntRange {
 
 	for _, r := range ranges[1:] {
 		last := &merged[len(merged)-1]
-		if r.Start <= last.End+1 {
+		if touches(*last, r) {
 			if r.End > last.End {
 				last.End = r.End
 			}
@@ -90,6 +123,14 @@ func mergeRanges(ranges []IntRange) []IntRange {
 	return merged
 }
 
+func countIDs(ranges []IntRange) *big.Int {
+	total := new(big.Int)
+	for _, r := range mergeRanges(ranges) {
+		total.Add(total, r.Len())
+	}
+	return total
+}
+
 func main() {
 	start := time.Now()
 
@@ -103,11 +144,7 @@ func main() {
 	}
 	fmt.Println("Number of available ingredient IDs that are fresh:", freshCount)
 
-	merged := mergeRanges(ranges)
-	total := 0
-	for _, r := range merged {
-		total += r.End - r.Start + 1
-	}
+	total := countIDs(ranges)
 	fmt.Println("Number of TOTAL available ingredient IDs that are fresh:", total)
 	log.Printf("Execution time: %s", time.Since(start))
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"math"
 	"testing"
 )
 
@@ -8,7 +9,7 @@ func TestIsIngredientFresh(t *testing.T) {
 	tests := []struct {
 		name   string
 		ranges []IntRange
-		id     int
+		id     int64
 		want   bool
 	}{
 		{
@@ -143,3 +144,154 @@ func TestMergeRanges(t *testing.T) {
 		})
 	}
 }
+
+func TestMergeRangesInt64Boundaries(t *testing.T) {
+	tests := []struct {
+		name   string
+		ranges []IntRange
+		want   []IntRange
+	}{
+		{
+			name: "Adjacent ranges ending at MaxInt64",
+			ranges: []IntRange{
+				{math.MaxInt64 - 5, math.MaxInt64 - 3},
+				{math.MaxInt64 - 2, math.MaxInt64},
+			},
+			want: []IntRange{
+				{math.MaxInt64 - 5, math.MaxInt64},
+			},
+		},
+		{
+			name: "Range ending at MaxInt64 does not absorb later starts",
+			ranges: []IntRange{
+				{math.MaxInt64, math.MaxInt64},
+				{0, 10},
+			},
+			want: []IntRange{
+				{0, 10},
+				{math.MaxInt64, math.MaxInt64},
+			},
+		},
+		{
+			name: "Adjacent ranges starting at MinInt64",
+			ranges: []IntRange{
+				{math.MinInt64 + 3, math.MinInt64 + 8},
+				{math.MinInt64, math.MinInt64 + 2},
+			},
+			want: []IntRange{
+				{math.MinInt64, math.MinInt64 + 8},
+			},
+		},
+		{
+			name: "Gap of one next to MinInt64",
+			ranges: []IntRange{
+				{math.MinInt64, math.MinInt64},
+				{math.MinInt64 + 2, math.MinInt64 + 2},
+			},
+			want: []IntRange{
+				{math.MinInt64, math.MinInt64},
+				{math.MinInt64 + 2, math.MinInt64 + 2},
+			},
+		},
+		{
+			name: "Whole int64 domain",
+			ranges: []IntRange{
+				{0, math.MaxInt64},
+				{math.MinInt64, -1},
+			},
+			want: []IntRange{
+				{math.MinInt64, math.MaxInt64},
+			},
+		},
+		{
+			name:   "Empty input",
+			ranges: nil,
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeRanges(tt.ranges)
+			if len(got) != len(tt.want) {
+				t.Fatalf("mergeRanges() returned %d ranges; want %d", len(got), len(tt.want))
+			}
+
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("range %d = %v; want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCountIDs(t *testing.T) {
+	tests := []struct {
+		name   string
+		ranges []IntRange
+		want   string
+	}{
+		{
+			name: "Example ranges",
+			ranges: []IntRange{
+				{3, 5}, {10, 14}, {16, 20}, {12, 18},
+			},
+			want: "14",
+		},
+		{
+			name: "Single ID at MaxInt64",
+			ranges: []IntRange{
+				{math.MaxInt64, math.MaxInt64},
+			},
+			want: "1",
+		},
+		{
+			name: "Non-negative half of the domain",
+			ranges: []IntRange{
+				{0, math.MaxInt64},
+			},
+			want: "9223372036854775808",
+		},
+		{
+			name: "Whole int64 domain",
+			ranges: []IntRange{
+				{math.MinInt64, -1},
+				{0, math.MaxInt64},
+			},
+			want: "18446744073709551616",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := countIDs(tt.ranges)
+			if got.String() != tt.want {
+				t.Errorf("countIDs() = %s; want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		line   string
+		want   IntRange
+		wantOK bool
+	}{
+		{"3-5", IntRange{3, 5}, true},
+		{"-10--5", IntRange{-10, -5}, true},
+		{"-9223372036854775808-9223372036854775807", IntRange{math.MinInt64, math.MaxInt64}, true},
+		{"17", IntRange{}, false},
+		{"-17", IntRange{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, ok := parseRange(tt.line)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("parseRange(%q) = %v, %v; want %v, %v", tt.line, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
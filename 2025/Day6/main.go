@@ -1,164 +1,92 @@
 package main
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
 	"log"
-	"os"
-	"strconv"
-	"strings"
+	"math/big"
 	"time"
 )
 
-func readInput(filename string) ([][]int, []string) {
-	file, err := os.Open(filename)
-	if err != nil {
-		log.Fatalf("failed to open file %q: %v", filename, err)
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	var rows [][]int
-	var ops []string
-
-	const numberLines = 4
-	lineIndex := 0
-
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		fields := strings.Fields(line)
-
-		if lineIndex < numberLines {
-			ints := make([]int, len(fields))
-			for i, v := range fields {
-				n, err := strconv.Atoi(v)
-				if err != nil {
-					log.Fatalf("invalid integer %q: %v", v, err)
-				}
-				ints[i] = n
-			}
-			rows = append(rows, ints)
-			lineIndex++
-			continue
+// applyOperator combines two numbers with op, switching from int64 to
+// math/big only when the int64 result would overflow.
+func applyOperator(a, b *big.Int, op string) (*big.Int, error) {
+	if a.IsInt64() && b.IsInt64() {
+		r, err := applyInt64(a.Int64(), b.Int64(), op)
+		if err == nil {
+			return big.NewInt(r), nil
+		}
+		if !errors.Is(err, errOverflow) {
+			return nil, err
 		}
-
-		// last line: operators
-		ops = fields
-	}
-
-	if err := scanner.Err(); err != nil {
-		log.Fatalf("scanner error: %v", err)
 	}
-
-	return rows, ops
+	return applyBig(a, b, op)
 }
 
-func readInputLeftToRight(filename string) [][]int {
-	file, err := os.Open(filename)
-	if err != nil {
-		log.Fatalf("failed to open file %q: %v", filename, err)
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	var lines []string
-
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+func calculateRow(nums []int, op string) (*big.Int, error) {
+	if !operators[op] {
+		return nil, fmt.Errorf("unsupported operator %q", op)
 	}
-	if err := scanner.Err(); err != nil {
-		log.Fatalf("scanner error: %v", err)
+	if len(nums) == 0 {
+		return nil, fmt.Errorf("no numbers to apply %q to", op)
 	}
 
-	// last line contains operators -> ignore
-	lines = lines[:len(lines)-1]
-
-	var result [][]int
-	var current []int
-
-	cols := len(lines[0])
-
-	for col := 0; col < cols; col++ {
-		var sb strings.Builder
-
-		for _, line := range lines {
-			if col < len(line) { // safe indexing
-				sb.WriteByte(line[col])
-			}
-		}
-
-		str := strings.TrimSpace(sb.String())
-		if str == "" {
-			// end of a number block
-			if len(current) > 0 {
-				result = append(result, current)
-				current = nil
-			}
-			continue
-		}
-
-		n, err := strconv.Atoi(str)
+	result := big.NewInt(int64(nums[0]))
+	for _, n := range nums[1:] {
+		var err error
+		result, err = applyOperator(result, big.NewInt(int64(n)), op)
 		if err != nil {
-			log.Fatalf("invalid number %q: %v", str, err)
+			return nil, err
 		}
-
-		current = append(current, n)
 	}
-
-	if len(current) > 0 {
-		result = append(result, current)
-	}
-
-	return result
+	return result, nil
 }
 
-func applyOperator(nums []int, op string) int {
-	if len(nums) != 2 {
-		log.Fatalf("applyOperator expects exactly 2 numbers, got %v", nums)
-	}
-
-	switch op {
-	case "+":
-		return nums[0] + nums[1]
-	case "*":
-		return nums[0] * nums[1]
-	default:
-		log.Fatalf("unsupported operator %q", op)
+// calculateProblem evaluates nums with the problem's operator and reports
+// failures with the problem's column span.
+func calculateProblem(p Problem, nums []int) (*big.Int, error) {
+	result, err := calculateRow(nums, p.Operator)
+	if err != nil {
+		return nil, fmt.Errorf("columns %d-%d: %w", p.Start, p.End-1, err)
 	}
-	return 0 // unreachable
+	return result, nil
 }
 
-func calculateRow(nums []int, op string) int {
-	result := nums[0]
-	for _, n := range nums[1:] {
-		result = applyOperator([]int{result, n}, op)
+// sumProblems reads every problem with mode and adds up the results.
+func sumProblems(ws Worksheet, mode ReadingMode) (*big.Int, error) {
+	total := new(big.Int)
+	for _, p := range ws.ProblemsIn(mode) {
+		nums, err := ws.Operands(p, mode)
+		if err != nil {
+			return nil, err
+		}
+		result, err := calculateProblem(p, nums)
+		if err != nil {
+			return nil, err
+		}
+		total.Add(total, result)
 	}
-	return result
+	return total, nil
 }
 
 func solve() {
 	start := time.Now()
 
-	grid, ops := readInput("./input.txt")
+	ws := readWorksheet("./input.txt")
 
 	// === PART 1 ===
-	part1 := 0
-	for col := range grid[0] {
-		var colValues []int
-		for _, row := range grid {
-			colValues = append(colValues, row[col])
-		}
-		part1 += calculateRow(colValues, ops[col])
+	part1, err := sumProblems(ws, RowReading)
+	if err != nil {
+		log.Fatalf("failed to solve worksheet: %v", err)
 	}
 	fmt.Println("Results:", part1)
 
 	// === PART 2 ===
-	part2 := 0
-	leftToRight := readInputLeftToRight("./input.txt")
-	for i, nums := range leftToRight {
-		part2 += calculateRow(nums, ops[i])
+	part2, err := sumProblems(ws, CephalopodReading)
+	if err != nil {
+		log.Fatalf("failed to solve worksheet: %v", err)
 	}
-	fmt.Println("Left To Right Results:", part2)
+	fmt.Println("Right To Left Results:", part2)
 
 	log.Printf("Execution time: %s", time.Since(start))
 }
@@ -1,35 +1,83 @@
 package main
 
 import (
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
 	"testing"
 )
 
 func TestApplyOperator(t *testing.T) {
 	tests := []struct {
 		name     string
-		numbers  []int
+		a, b     int64
 		operator string
-		want     int
+		want     string
 	}{
 		{
 			name:     "Additon",
-			numbers:  []int{1, 2},
+			a:        1,
+			b:        2,
 			operator: "+",
-			want:     3,
+			want:     "3",
 		},
 		{
 			name:     "Multiplication",
-			numbers:  []int{1, 2},
+			a:        1,
+			b:        2,
 			operator: "*",
-			want:     2,
+			want:     "2",
 		},
+		{name: "Subtraction", a: 1, b: 2, operator: "-", want: "-1"},
+		{name: "Division truncates toward zero", a: -7, b: 2, operator: "/", want: "-3"},
+		{name: "Remainder keeps dividend sign", a: -7, b: 2, operator: "%", want: "-1"},
+		{name: "Power", a: 3, b: 4, operator: "^", want: "81"},
+		{name: "Zero power", a: 0, b: 0, operator: "^", want: "1"},
+		{name: "Min", a: 5, b: -2, operator: "min", want: "-2"},
+		{name: "Min short form", a: 5, b: -2, operator: "<", want: "-2"},
+		{name: "Max", a: 5, b: -2, operator: "max", want: "5"},
+		{name: "Max short form", a: 5, b: -2, operator: ">", want: "5"},
+		{name: "Addition overflow", a: math.MaxInt64, b: 1, operator: "+", want: "9223372036854775808"},
+		{name: "Subtraction overflow", a: math.MinInt64, b: 1, operator: "-", want: "-9223372036854775809"},
+		{name: "Multiplication overflow", a: math.MaxInt64, b: 2, operator: "*", want: "18446744073709551614"},
+		{name: "Division overflow", a: math.MinInt64, b: -1, operator: "/", want: "9223372036854775808"},
+		{name: "Power overflow", a: 2, b: 64, operator: "^", want: "18446744073709551616"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyOperator(big.NewInt(tt.a), big.NewInt(tt.b), tt.operator)
+			if err != nil {
+				t.Fatalf("applyOperator(%d, %d, %s) error: %v", tt.a, tt.b, tt.operator, err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("applyOperator(%d, %d, %s) = %v; want %s", tt.a, tt.b, tt.operator, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyOperatorErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     int64
+		operator string
+	}{
+		{name: "Division by zero", a: 1, b: 0, operator: "/"},
+		{name: "Modulo by zero", a: 1, b: 0, operator: "%"},
+		{name: "Negative exponent", a: 2, b: -1, operator: "^"},
+		{name: "Huge power", a: 10, b: math.MaxInt64, operator: "^"},
+		{name: "Huge power wrapping the size check", a: 4, b: 1 << 62, operator: "^"},
+		{name: "Huge power of ten", a: 10, b: 4e18, operator: "^"},
+		{name: "Huge negative base", a: -4, b: 1 << 62, operator: "^"},
+		{name: "Unknown operator", a: 1, b: 2, operator: "&"},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := applyOperator(tt.numbers, tt.operator)
-			if got != tt.want {
-				t.Errorf("applyOperator(%v, %s) = %v; want %d", tt.numbers, tt.operator, got, tt.want)
+			if got, err := applyOperator(big.NewInt(tt.a), big.NewInt(tt.b), tt.operator); err == nil {
+				t.Errorf("applyOperator(%d, %d, %s) = %v; want error", tt.a, tt.b, tt.operator, got)
 			}
 		})
 	}
@@ -40,28 +88,353 @@ func TestCalculateRow(t *testing.T) {
 		name     string
 		numbers  []int
 		operator string
-		want     int
+		want     string
 	}{
 		{
 			name:     "Addition",
 			numbers:  []int{1, 2, 3},
 			operator: "+",
-			want:     6,
+			want:     "6",
 		},
 		{
 			name:     "Multiplication",
 			numbers:  []int{1, 2, 3, 4},
 			operator: "*",
-			want:     24,
+			want:     "24",
+		},
+		{name: "Subtraction is left to right", numbers: []int{10, 3, 2}, operator: "-", want: "5"},
+		{name: "Division is left to right", numbers: []int{100, 5, 2}, operator: "/", want: "10"},
+		{name: "Power is left to right", numbers: []int{2, 3, 2}, operator: "^", want: "64"},
+		{name: "Min", numbers: []int{4, 9, 1, 7}, operator: "min", want: "1"},
+		{name: "Max", numbers: []int{4, 9, 1, 7}, operator: "max", want: "9"},
+		{
+			name:     "Long product falls back to big integers",
+			numbers:  []int{1_000_000, 1_000_000, 1_000_000, 1_000_000},
+			operator: "*",
+			want:     "1000000000000000000000000",
+		},
+		{
+			name:     "Sum continues past MaxInt64",
+			numbers:  []int{math.MaxInt64, 1, 2},
+			operator: "+",
+			want:     "9223372036854775810",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := calculateRow(tt.numbers, tt.operator)
+			if err != nil {
+				t.Fatalf("calculateRow(%v, %s) error: %v", tt.numbers, tt.operator, err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("calculateRow(%v, %s) = %v; want %s", tt.numbers, tt.operator, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalculateProblemNamesColumn(t *testing.T) {
+	ws, err := parseWorksheet([]string{
+		"12 34",
+		" 5  6",
+		"+  & ",
+	})
+	if err != nil {
+		t.Fatalf("parseWorksheet() error: %v", err)
+	}
+
+	p := ws.Problems[1]
+	nums, err := ws.Operands(p, RowReading)
+	if err != nil {
+		t.Fatalf("Operands() error: %v", err)
+	}
+	_, err = calculateProblem(p, nums)
+	if err == nil {
+		t.Fatalf("calculateProblem() = nil error; want error for operator %q", p.Operator)
+	}
+	if !strings.Contains(err.Error(), "columns 3-4") || !strings.Contains(err.Error(), `"&"`) {
+		t.Errorf("calculateProblem() error = %q; want it to name columns 3-4 and operator \"&\"", err)
+	}
+}
+
+// readProblem is a problem together with its numbers read horizontally
+// (Rows) and column by column, left to right (Columns).
+type readProblem struct {
+	Rows     []int
+	Columns  []int
+	Operator string
+	Start    int
+	End      int
+}
+
+// readProblems reads back every problem of ws in both directions.
+func readProblems(t *testing.T, ws Worksheet) []readProblem {
+	t.Helper()
+	var out []readProblem
+	for _, p := range ws.Problems {
+		rows, err := ws.Operands(p, RowReading)
+		if err != nil {
+			t.Fatalf("Operands(%+v, RowReading) error: %v", p, err)
+		}
+		cols, err := ws.Operands(p, ReadingMode{Vertical: true})
+		if err != nil {
+			t.Fatalf("Operands(%+v, vertical) error: %v", p, err)
+		}
+		out = append(out, readProblem{Rows: rows, Columns: cols, Operator: p.Operator, Start: p.Start, End: p.End})
+	}
+	return out
+}
+
+var exampleWorksheet = []string{
+	"123 328  51 64 ",
+	" 45 64  387 23 ",
+	"  6 98  215 314",
+	"*   +   *   +  ",
+}
+
+func TestParseWorksheetExample(t *testing.T) {
+	ws, err := parseWorksheet(exampleWorksheet)
+	if err != nil {
+		t.Fatalf("parseWorksheet() error: %v", err)
+	}
+
+	want := []readProblem{
+		{Rows: []int{123, 45, 6}, Columns: []int{1, 24, 356}, Operator: "*", Start: 0, End: 3},
+		{Rows: []int{328, 64, 98}, Columns: []int{369, 248, 8}, Operator: "+", Start: 4, End: 7},
+		{Rows: []int{51, 387, 215}, Columns: []int{32, 581, 175}, Operator: "*", Start: 8, End: 11},
+		{Rows: []int{64, 23, 314}, Columns: []int{623, 431, 4}, Operator: "+", Start: 12, End: 15},
+	}
+	if got := readProblems(t, ws); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseWorksheet() problems = %+v; want %+v", got, want)
+	}
+
+	part1, err := sumProblems(ws, RowReading)
+	if err != nil || part1.Int64() != 4277556 {
+		t.Errorf("part 1 = %v, %v; want 4277556", part1, err)
+	}
+	part2, err := sumProblems(ws, CephalopodReading)
+	if err != nil || part2.Int64() != 3263827 {
+		t.Errorf("part 2 = %v, %v; want 3263827", part2, err)
+	}
+}
+
+func TestParseWorksheetLayouts(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  []readProblem
+	}{
+		{
+			name: "Two operand rows",
+			lines: []string{
+				"12 3",
+				" 4 5",
+				"+  *",
+			},
+			want: []readProblem{
+				{Rows: []int{12, 4}, Columns: []int{1, 24}, Operator: "+", Start: 0, End: 2},
+				{Rows: []int{3, 5}, Columns: []int{35}, Operator: "*", Start: 3, End: 4},
+			},
+		},
+		{
+			name: "Six operand rows",
+			lines: []string{
+				"1", "2", "3", "4", "5", "6",
+				"+",
+			},
+			want: []readProblem{
+				{Rows: []int{1, 2, 3, 4, 5, 6}, Columns: []int{123456}, Operator: "+", Start: 0, End: 1},
+			},
+		},
+		{
+			name: "Ragged line lengths and trailing blank lines",
+			lines: []string{
+				"10  7",
+				"2",
+				"*   +",
+				"",
+				"   ",
+			},
+			want: []readProblem{
+				{Rows: []int{10, 2}, Columns: []int{12, 0}, Operator: "*", Start: 0, End: 2},
+				{Rows: []int{7}, Columns: []int{7}, Operator: "+", Start: 4, End: 5},
+			},
+		},
+		{
+			name: "Blocks without a blank separator column",
+			lines: []string{
+				"1234",
+				"5678",
+				"+ * ",
+			},
+			want: []readProblem{
+				{Rows: []int{12, 56}, Columns: []int{15, 26}, Operator: "+", Start: 0, End: 2},
+				{Rows: []int{34, 78}, Columns: []int{37, 48}, Operator: "*", Start: 2, End: 4},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws, err := parseWorksheet(tt.lines)
+			if err != nil {
+				t.Fatalf("parseWorksheet() error: %v", err)
+			}
+			if got := readProblems(t, ws); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseWorksheet() problems = %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseWorksheetErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+	}{
+		{name: "Empty", lines: nil},
+		{name: "Operator row only", lines: []string{"+ *"}},
+		{name: "Block without operator", lines: []string{"1 2", "+  "}},
+		{name: "Invalid number", lines: []string{"1x", "+ "}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseWorksheet(tt.lines); err == nil {
+				t.Errorf("parseWorksheet(%q) = nil error; want error", tt.lines)
+			}
+		})
+	}
+}
+
+func TestReadingModesGolden(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     ReadingMode
+		operands [][]int
+		total    int64
+	}{
+		{
+			name:     "Rows",
+			mode:     RowReading,
+			operands: [][]int{{123, 45, 6}, {328, 64, 98}, {51, 387, 215}, {64, 23, 314}},
+			total:    4277556,
+		},
+		{
+			name:     "Rows bottom to top",
+			mode:     ReadingMode{BottomToTop: true},
+			operands: [][]int{{6, 45, 123}, {98, 64, 328}, {215, 387, 51}, {314, 23, 64}},
+			total:    4277556,
+		},
+		{
+			name:     "Rows right to left",
+			mode:     ReadingMode{RightToLeft: true},
+			operands: [][]int{{64, 23, 314}, {51, 387, 215}, {328, 64, 98}, {123, 45, 6}},
+			total:    4277556,
+		},
+		{
+			name:     "Columns left to right",
+			mode:     ReadingMode{Vertical: true},
+			operands: [][]int{{1, 24, 356}, {369, 248, 8}, {32, 581, 175}, {623, 431, 4}},
+			total:    3263827,
+		},
+		{
+			name:     "Cephalopod",
+			mode:     CephalopodReading,
+			operands: [][]int{{4, 431, 623}, {175, 581, 32}, {8, 248, 369}, {356, 24, 1}},
+			total:    3263827,
+		},
+		{
+			name:     "Columns left-aligned",
+			mode:     ReadingMode{Vertical: true, Align: AlignLeft},
+			operands: [][]int{{146, 25, 3}, {369, 248, 8}, {532, 181, 75}, {623, 431, 4}},
+			total:    7234533,
+		},
+		{
+			name:     "Columns right-aligned",
+			mode:     ReadingMode{Vertical: true, Align: AlignRight},
+			operands: [][]int{{1, 24, 356}, {3, 269, 848}, {32, 581, 175}, {3, 621, 434}},
+			total:    3264322,
+		},
+		{
+			name:     "Columns bottom to top",
+			mode:     ReadingMode{Vertical: true, BottomToTop: true},
+			operands: [][]int{{1, 42, 653}, {963, 842, 8}, {23, 185, 571}, {326, 134, 4}},
+			total:    2459308,
+		},
+		{
+			name:     "Columns bottom to top, left-aligned",
+			mode:     ReadingMode{Vertical: true, BottomToTop: true, Align: AlignLeft},
+			operands: [][]int{{641, 52, 3}, {963, 842, 8}, {235, 181, 57}, {326, 134, 4}},
+			total:    2526768,
+		},
+		{
+			name:     "Cephalopod bottom to top, right-aligned",
+			mode:     ReadingMode{Vertical: true, RightToLeft: true, BottomToTop: true, Align: AlignRight},
+			operands: [][]int{{434, 126, 3}, {571, 185, 23}, {848, 962, 3}, {653, 42, 1}},
+			total:    2459407,
 		},
 	}
 
+	ws, err := parseWorksheet(exampleWorksheet)
+	if err != nil {
+		t.Fatalf("parseWorksheet() error: %v", err)
+	}
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := calculateRow(tt.numbers, tt.operator)
-			if got != tt.want {
-				t.Errorf("calculateRow(%v, %s) = %v; want %d", tt.numbers, tt.operator, got, tt.want)
+			var got [][]int
+			for _, p := range ws.ProblemsIn(tt.mode) {
+				nums, err := ws.Operands(p, tt.mode)
+				if err != nil {
+					t.Fatalf("Operands() error: %v", err)
+				}
+				got = append(got, nums)
+			}
+			if !reflect.DeepEqual(got, tt.operands) {
+				t.Errorf("operands = %v; want %v", got, tt.operands)
+			}
+
+			total, err := sumProblems(ws, tt.mode)
+			if err != nil {
+				t.Fatalf("sumProblems() error: %v", err)
+			}
+			if total.Int64() != tt.total {
+				t.Errorf("sumProblems() = %v; want %d", total, tt.total)
 			}
 		})
 	}
 }
+
+func TestReadingModeOrderMatters(t *testing.T) {
+	ws, err := parseWorksheet([]string{
+		"100",
+		" 20",
+		"  5",
+		"-  ",
+	})
+	if err != nil {
+		t.Fatalf("parseWorksheet() error: %v", err)
+	}
+
+	tests := []struct {
+		mode ReadingMode
+		want int64
+	}{
+		{mode: RowReading, want: 75},
+		{mode: ReadingMode{BottomToTop: true}, want: -115},
+		{mode: ReadingMode{Vertical: true}, want: 1 - 2 - 5},
+		{mode: CephalopodReading, want: 5 - 2 - 1},
+	}
+
+	for _, tt := range tests {
+		got, err := sumProblems(ws, tt.mode)
+		if err != nil {
+			t.Fatalf("sumProblems(%+v) error: %v", tt.mode, err)
+		}
+		if got.Int64() != tt.want {
+			t.Errorf("sumProblems(%+v) = %v; want %d", tt.mode, got, tt.want)
+		}
+	}
+}
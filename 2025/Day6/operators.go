@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// operators lists the supported operators. Every operator folds a problem's
+// numbers strictly left to right, so "-" on 10, 3, 2 is (10-3)-2 and "^" on
+// 2, 3, 2 is (2^3)^2. "/" truncates toward zero, "%" takes the sign of the
+// dividend and "^" rejects negative exponents. "min" and "max" (or their
+// one-column spellings "<" and ">") pick the smallest and largest number.
+var operators = map[string]bool{
+	"+": true, "-": true, "*": true, "/": true, "%": true, "^": true,
+	"min": true, "<": true, "max": true, ">": true,
+}
+
+// maxPowBits caps the size of a power result so a stray "^" cannot make the
+// big.Int path allocate without bound.
+const maxPowBits = 1 << 20
+
+var errOverflow = errors.New("int64 overflow")
+
+// applyInt64 applies op to a and b. It returns errOverflow when the result
+// does not fit in an int64, so the caller can redo the step with math/big.
+func applyInt64(a, b int64, op string) (int64, error) {
+	switch op {
+	case "+":
+		if (b > 0 && a > math.MaxInt64-b) || (b < 0 && a < math.MinInt64-b) {
+			return 0, errOverflow
+		}
+		return a + b, nil
+	case "-":
+		if (b < 0 && a > math.MaxInt64+b) || (b > 0 && a < math.MinInt64+b) {
+			return 0, errOverflow
+		}
+		return a - b, nil
+	case "*":
+		return mulInt64(a, b)
+	case "/":
+		if b == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		if a == math.MinInt64 && b == -1 {
+			return 0, errOverflow
+		}
+		return a / b, nil
+	case "%":
+		if b == 0 {
+			return 0, fmt.Errorf("modulo by zero")
+		}
+		return a % b, nil
+	case "^":
+		return powInt64(a, b)
+	case "min", "<":
+		return min(a, b), nil
+	case "max", ">":
+		return max(a, b), nil
+	}
+	return 0, fmt.Errorf("unsupported operator %q", op)
+}
+
+func mulInt64(a, b int64) (int64, error) {
+	if a == 0 || b == 0 {
+		return 0, nil
+	}
+	r := a * b
+	if r/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, errOverflow
+	}
+	return r, nil
+}
+
+func powInt64(base, exp int64) (int64, error) {
+	if exp < 0 {
+		return 0, fmt.Errorf("negative exponent %d", exp)
+	}
+
+	result := int64(1)
+	for exp > 0 {
+		var err error
+		if exp&1 == 1 {
+			if result, err = mulInt64(result, base); err != nil {
+				return 0, err
+			}
+		}
+		exp >>= 1
+		if exp > 0 {
+			if base, err = mulInt64(base, base); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return result, nil
+}
+
+// applyBig is the math/big counterpart of applyInt64 and never overflows.
+func applyBig(a, b *big.Int, op string) (*big.Int, error) {
+	r := new(big.Int)
+	switch op {
+	case "+":
+		return r.Add(a, b), nil
+	case "-":
+		return r.Sub(a, b), nil
+	case "*":
+		return r.Mul(a, b), nil
+	case "/":
+		if b.Sign() == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return r.Quo(a, b), nil
+	case "%":
+		if b.Sign() == 0 {
+			return nil, fmt.Errorf("modulo by zero")
+		}
+		return r.Rem(a, b), nil
+	case "^":
+		if b.Sign() < 0 {
+			return nil, fmt.Errorf("negative exponent %s", b)
+		}
+		// The result has at least (BitLen(a)-1)*b bits. Divide rather than
+		// multiply so a huge exponent cannot wrap the check around.
+		if bits := int64(a.BitLen() - 1); a.CmpAbs(big.NewInt(1)) > 0 && (!b.IsInt64() || b.Int64() > maxPowBits/bits) {
+			return nil, fmt.Errorf("power %s^%s is too large", a, b)
+		}
+		return r.Exp(a, b, nil), nil
+	case "min", "<":
+		if a.Cmp(b) <= 0 {
+			return r.Set(a), nil
+		}
+		return r.Set(b), nil
+	case "max", ">":
+		if a.Cmp(b) >= 0 {
+			return r.Set(a), nil
+		}
+		return r.Set(b), nil
+	}
+	return nil, fmt.Errorf("unsupported operator %q", op)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Alignment says how each row's number sits inside its problem block before
+// the block is read column by column.
+type Alignment int
+
+const (
+	// AlignAsWritten keeps every character where the worksheet put it.
+	AlignAsWritten Alignment = iota
+	// AlignLeft pushes each row's number to the left edge of the block.
+	AlignLeft
+	// AlignRight pushes each row's number to the right edge of the block.
+	AlignRight
+)
+
+// ReadingMode selects one way of turning a worksheet block into operands.
+//
+// Vertical false reads every operand row as one number; Vertical true reads
+// every character column as one number, the cephalopod way.
+// RightToLeft reverses the order of the problems and, in vertical mode, the
+// order of the columns inside each problem.
+// BottomToTop reverses the operand rows: in horizontal mode the numbers come
+// bottom row first, in vertical mode each column's digits are read upwards.
+// Align only matters in vertical mode.
+type ReadingMode struct {
+	Vertical    bool
+	RightToLeft bool
+	BottomToTop bool
+	Align       Alignment
+}
+
+var (
+	// RowReading is the part 1 reading.
+	RowReading = ReadingMode{}
+	// CephalopodReading is the part 2 reading: columns right to left, digits
+	// top to bottom.
+	CephalopodReading = ReadingMode{Vertical: true, RightToLeft: true}
+)
+
+// ProblemsIn returns the worksheet's problems in the mode's order.
+func (ws Worksheet) ProblemsIn(mode ReadingMode) []Problem {
+	problems := slices.Clone(ws.Problems)
+	if mode.RightToLeft {
+		slices.Reverse(problems)
+	}
+	return problems
+}
+
+// Operands reads p's numbers from the raw worksheet lines using mode.
+func (ws Worksheet) Operands(p Problem, mode ReadingMode) ([]int, error) {
+	return readOperands(ws.Lines, p.Start, p.End, mode)
+}
+
+func readOperands(rows []string, start, end int, mode ReadingMode) ([]int, error) {
+	rows = slices.Clone(rows)
+	if mode.BottomToTop {
+		slices.Reverse(rows)
+	}
+
+	if !mode.Vertical {
+		var nums []int
+		for _, row := range rows {
+			field := strings.TrimSpace(row[start:end])
+			if field == "" {
+				continue
+			}
+			n, err := strconv.Atoi(field)
+			if err != nil {
+				return nil, fmt.Errorf("columns %d-%d: invalid number %q", start, end-1, field)
+			}
+			nums = append(nums, n)
+		}
+		return nums, nil
+	}
+
+	width := end - start
+	block := make([]string, len(rows))
+	for i, row := range rows {
+		field := row[start:end]
+		switch mode.Align {
+		case AlignLeft:
+			field = strings.TrimSpace(field)
+			field += strings.Repeat(" ", width-len(field))
+		case AlignRight:
+			field = strings.TrimSpace(field)
+			field = strings.Repeat(" ", width-len(field)) + field
+		}
+		block[i] = field
+	}
+
+	var nums []int
+	for c := range width {
+		var sb strings.Builder
+		for _, field := range block {
+			sb.WriteByte(field[c])
+		}
+		digits := strings.TrimSpace(sb.String())
+		if digits == "" {
+			continue
+		}
+		n, err := strconv.Atoi(digits)
+		if err != nil {
+			return nil, fmt.Errorf("column %d: invalid number %q", start+c, digits)
+		}
+		nums = append(nums, n)
+	}
+	if mode.RightToLeft {
+		slices.Reverse(nums)
+	}
+	return nums, nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// Problem is one vertical block of the worksheet, covering worksheet
+// columns [Start, End). Its numbers are read from the worksheet lines with
+// Worksheet.Operands, in whichever ReadingMode is wanted.
+type Problem struct {
+	Operator string
+	Start    int
+	End      int
+}
+
+// Worksheet is a parsed homework sheet. Lines holds the operand rows padded
+// with spaces to a common width, so every column index is valid on every row.
+type Worksheet struct {
+	Lines    []string
+	Problems []Problem
+}
+
+// readWorksheet loads and parses the worksheet in filename.
+func readWorksheet(filename string) Worksheet {
+	file, err := os.Open(filename)
+	if err != nil {
+		log.Fatalf("failed to open file %q: %v", filename, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatalf("scanner error: %v", err)
+	}
+
+	ws, err := parseWorksheet(lines)
+	if err != nil {
+		log.Fatalf("invalid worksheet %q: %v", filename, err)
+	}
+	return ws
+}
+
+// parseWorksheet parses raw worksheet lines. The last non-blank line holds
+// the operators and every non-blank line above it is an operand row, so the
+// number of rows is detected rather than fixed. Lines may have different
+// lengths; missing characters count as spaces.
+func parseWorksheet(lines []string) (Worksheet, error) {
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) < 2 {
+		return Worksheet{}, fmt.Errorf("need at least one operand row and an operator row, got %d lines", len(lines))
+	}
+
+	var rows []string
+	for _, line := range lines[:len(lines)-1] {
+		if strings.TrimSpace(line) != "" {
+			rows = append(rows, line)
+		}
+	}
+	opLine := lines[len(lines)-1]
+
+	width := len(opLine)
+	for _, row := range rows {
+		width = max(width, len(row))
+	}
+	for i, row := range rows {
+		rows[i] = row + strings.Repeat(" ", width-len(row))
+	}
+	opLine += strings.Repeat(" ", width-len(opLine))
+
+	ws := Worksheet{Lines: rows}
+	for _, span := range problemSpans(rows, opLine) {
+		p, err := parseProblem(rows, opLine, span[0], span[1])
+		if err != nil {
+			return Worksheet{}, err
+		}
+		ws.Problems = append(ws.Problems, p)
+	}
+	if len(ws.Problems) == 0 {
+		return Worksheet{}, fmt.Errorf("no problems found")
+	}
+
+	return ws, nil
+}
+
+// problemSpans splits the worksheet into column blocks. Blocks are normally
+// separated by all-blank columns; when two operators share a block with no
+// blank column between them, the block is also split at each operator.
+func problemSpans(rows []string, opLine string) [][2]int {
+	blank := func(col int) bool {
+		if opLine[col] != ' ' {
+			return false
+		}
+		for _, row := range rows {
+			if row[col] != ' ' {
+				return false
+			}
+		}
+		return true
+	}
+
+	var spans [][2]int
+	start := -1
+	for col := 0; col <= len(opLine); col++ {
+		if col < len(opLine) && !blank(col) {
+			if start < 0 {
+				start = col
+			} else if opLine[col] != ' ' && opLine[col-1] == ' ' && strings.TrimSpace(opLine[start:col]) != "" {
+				spans = append(spans, [2]int{start, col})
+				start = col
+			}
+			continue
+		}
+		if start >= 0 {
+			spans = append(spans, [2]int{start, col})
+			start = -1
+		}
+	}
+	return spans
+}
+
+func parseProblem(rows []string, opLine string, start, end int) (Problem, error) {
+	p := Problem{Start: start, End: end}
+
+	op := strings.Fields(opLine[start:end])
+	if len(op) != 1 {
+		return Problem{}, fmt.Errorf("columns %d-%d: expected one operator, got %q", start, end-1, op)
+	}
+	p.Operator = op[0]
+
+	// Both readings must parse, so a bad digit is reported here rather
+	// than while solving.
+	nums, err := readOperands(rows, start, end, RowReading)
+	if err != nil {
+		return Problem{}, err
+	}
+	if _, err := readOperands(rows, start, end, ReadingMode{Vertical: true}); err != nil {
+		return Problem{}, err
+	}
+
+	if len(nums) == 0 {
+		return Problem{}, fmt.Errorf("columns %d-%d: no operands", start, end-1)
+	}
+
+	return p, nil
+}
@@ -1,12 +1,11 @@
 package main
 
 import (
-	"bufio"
+	"flag"
 	"fmt"
 	"log"
+	"math/big"
 	"os"
-	"slices"
-	"strings"
 	"time"
 )
 
@@ -15,136 +14,53 @@ type Pos struct {
 	col int
 }
 
-type PosMap map[Pos]int
-
-func AllIndexesASCII(s string, target []byte) []int {
-	var idxs []int
-	for i := 0; i < len(s); i++ {
-		if slices.Contains(target, s[i]) {
-			idxs = append(idxs, i)
-		}
-	}
-	return idxs
-}
-
-func readAndSolveInput(filename string) int {
-	file, _ := os.Open(filename)
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	var lines []string
-	var newLine []byte
-	splits := 0
-
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-
-		if len(lines) == 0 {
-			lines = append(lines, line)
-			continue
-		}
-
-		prevLine := lines[len(lines)-1]
-		newLine = []byte(line)
-
-		allSplittersIndexes := AllIndexesASCII(line, []byte{'^'})
-		for _, i := range allSplittersIndexes {
-			if prevLine[i] == '|' || prevLine[i] == 'S' {
-				splits++
-				newLine[i-1] = '|'
-				newLine[i+1] = '|'
-			}
-		}
-
-		allBeamIndexes := AllIndexesASCII(prevLine, []byte{'|', 'S'})
-		for _, i := range allBeamIndexes {
-			if (prevLine[i] == '|' || prevLine[i] == 'S') && newLine[i] != '^' {
-				newLine[i] = '|'
-			}
-		}
-
-		lines = append(lines, string(newLine))
-	}
-
-	SaveLines(strings.Split(strings.Split(filename, "/")[1], ".")[0]+"_filled.txt", lines)
-
-	return splits
-}
-
-func SaveLines(filename string, lines []string) error {
-	data := strings.Join(lines, "\n")
-	return os.WriteFile(filename, []byte(data), 0644)
-}
-
-func readDiagram(filename string) []string {
-	file, _ := os.Open(filename)
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	var lines []string
-
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		lines = append(lines, line)
+func mustReadManifold(filename string) *Manifold {
+	m, err := ReadManifold(filename)
+	if err != nil {
+		log.Fatalf("failed to read manifold %q: %v", filename, err)
 	}
-
-	return lines
+	return m
 }
 
-func travel(diagram []string, pos Pos) int {
-	// base case
-	if pos.row == len(diagram)-1 {
-		return 1
-	}
-
-	// cache check
-	if v, ok := cache[pos]; ok {
-		return v
+func mustTimelines(m *Manifold) *big.Int {
+	n, err := m.Timelines()
+	if err != nil {
+		log.Fatalf("failed to count timelines: %v", err)
 	}
-
-	var result int
-
-	switch diagram[pos.row+1][pos.col] {
-	case '.':
-		result = travel(diagram, Pos{row: pos.row + 1, col: pos.col})
-	case '^':
-		left := travel(diagram, Pos{pos.row + 1, pos.col - 1})
-		right := travel(diagram, Pos{pos.row + 1, pos.col + 1})
-		result = left + right
-	default:
-		panic("unexpected character in diagram")
-	}
-
-	// store in cache BEFORE returning
-	cache[pos] = result
-	return result
+	return n
 }
 
-var cache = make(map[Pos]int)
-
 func main() {
+	svgPath := flag.String("svg", "", "write the input manifold as an SVG image to this file")
+	flag.Parse()
+
 	start := time.Now()
 
+	test := mustReadManifold("./test.txt")
+	input := mustReadManifold("./input.txt")
+
 	// === TEST 1 ===
-	test_splits := readAndSolveInput("./test.txt")
-	fmt.Printf("TEST 1: There were %d splits in the TEST file (wanted: 21).\n", test_splits)
+	fmt.Printf("TEST 1: There were %d splits in the TEST file (wanted: 21).\n", test.SplitCount())
 
 	// === PART 1 ===
-	splits := readAndSolveInput("./input.txt")
-	fmt.Printf("PART 1: There were %d splits.\n", splits)
+	fmt.Printf("PART 1: There were %d splits.\n", input.SplitCount())
 
 	// === TEST 2 ===
-	testDiagram := readDiagram("./test.txt")
-	testStartingPoint := strings.Index(testDiagram[0], "S")
-	testActiveTimelines := travel(testDiagram, Pos{row: 1, col: testStartingPoint})
-	fmt.Printf("TEST 2: Active timelines: %d (wanted: 40)\n", testActiveTimelines)
+	fmt.Printf("TEST 2: Active timelines: %d (wanted: 40)\n", mustTimelines(test))
 
 	// === PART 2 ===
-	cache = make(map[Pos]int)
-	diagram := readDiagram("./input.txt")
-	startingPoint := strings.Index(diagram[0], "S")
-	activeTimelines := travel(diagram, Pos{row: 1, col: startingPoint})
-	fmt.Printf("PART 2: Active timelines: %d\n", activeTimelines)
+	fmt.Printf("PART 2: Active timelines: %d\n", mustTimelines(input))
+
+	if *svgPath != "" {
+		f, err := os.Create(*svgPath)
+		if err != nil {
+			log.Fatalf("failed to create %q: %v", *svgPath, err)
+		}
+		defer f.Close()
+		if err := input.WriteSVG(f); err != nil {
+			log.Fatalf("failed to write SVG: %v", err)
+		}
+	}
 
 	log.Printf("Execution time: %s", time.Since(start))
 }
@@ -0,0 +1,536 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const example = `.......S.......
+...............
+.......^.......
+...............
+......^.^......
+...............
+.....^.^.^.....
+...............
+....^.^...^....
+...............
+...^.^...^.^...
+...............
+..^...^.....^..
+...............
+.^.^.^.^.^...^.
+...............
+`
+
+func TestManifoldExample(t *testing.T) {
+	m, err := ParseManifold(example)
+	if err != nil {
+		t.Fatalf("ParseManifold() error: %v", err)
+	}
+
+	if got := m.SplitCount(); got != 21 {
+		t.Errorf("SplitCount() = %d; want 21", got)
+	}
+	if got, err := m.Timelines(); err != nil || got.Int64() != 40 {
+		t.Errorf("Timelines() = %v, %v; want 40", got, err)
+	}
+	if got := m.Start(); got != (Pos{row: 0, col: 7}) {
+		t.Errorf("Start() = %v; want {0 7}", got)
+	}
+
+	want, err := os.ReadFile("test_filled.txt")
+	if err != nil {
+		t.Fatalf("reading test_filled.txt: %v", err)
+	}
+	if got := strings.Join(m.Filled(), "\n"); got != string(want) {
+		t.Errorf("Filled() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestReadManifoldWithoutSlash(t *testing.T) {
+	m, err := ReadManifold("test.txt")
+	if err != nil {
+		t.Fatalf("ReadManifold() error: %v", err)
+	}
+	if got := m.SplitCount(); got != 21 {
+		t.Errorf("SplitCount() = %d; want 21", got)
+	}
+}
+
+func TestManifoldSideEdges(t *testing.T) {
+	m, err := ParseManifold("S.\n^.\n..\n")
+	if err != nil {
+		t.Fatalf("ParseManifold() error: %v", err)
+	}
+
+	if got := m.SplitCount(); got != 1 {
+		t.Errorf("SplitCount() = %d; want 1", got)
+	}
+	if got, err := m.Timelines(); err != nil || got.Int64() != 2 {
+		t.Errorf("Timelines() = %v, %v; want 2", got, err)
+	}
+	if got, want := strings.Join(m.Filled(), "\n"), "S.\n^|\n.|"; got != want {
+		t.Errorf("Filled() = %q; want %q", got, want)
+	}
+}
+
+func TestParseManifoldErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+	}{
+		{name: "No start", text: "...\n.^.\n"},
+		{name: "Two starts", text: "S.S\n...\n"},
+		{name: "Ragged lines", text: ".S.\n..\n"},
+		{name: "Unknown character", text: ".S.\n.x.\n"},
+		{name: "Lower-case start", text: ".s.\n...\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseManifold(tt.text); err == nil {
+				t.Errorf("ParseManifold(%q) = nil error; want error", tt.text)
+			}
+		})
+	}
+}
+
+func TestManifoldConcurrentSolving(t *testing.T) {
+	manifolds := make([]*Manifold, 0, 2)
+	for _, text := range []string{example, "S.\n^.\n..\n"} {
+		m, err := ParseManifold(text)
+		if err != nil {
+			t.Fatalf("ParseManifold() error: %v", err)
+		}
+		manifolds = append(manifolds, m)
+	}
+	want := []int{40, 2}
+
+	var wg sync.WaitGroup
+	for range 8 {
+		for i, m := range manifolds {
+			wg.Go(func() {
+				if got, err := m.Timelines(); err != nil || got.Int64() != int64(want[i]) {
+					t.Errorf("Timelines() = %v, %v; want %d", got, err, want[i])
+				}
+				m.Filled()
+			})
+		}
+	}
+	wg.Wait()
+}
+
+func TestTimelinesByRow(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []int64
+	}{
+		{
+			name: "Example",
+			text: example,
+			want: []int64{1, 1, 2, 2, 4, 4, 8, 8, 13, 13, 20, 20, 26, 26, 40, 40},
+		},
+		{
+			name: "Timelines leaving through both edges",
+			text: ".S.\n.^.\n^.^\n.^.\n^.^\n.^.\n^.^\n",
+			want: []int64{1, 2, 4, 6, 10, 14, 22},
+		},
+		{
+			name: "Start below the first row",
+			text: "...\n.S.\n.^.\n",
+			want: []int64{0, 1, 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := ParseManifold(tt.text)
+			if err != nil {
+				t.Fatalf("ParseManifold() error: %v", err)
+			}
+
+			got, err := m.TimelinesByRow()
+			if err != nil {
+				t.Fatalf("TimelinesByRow() error: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("TimelinesByRow() returned %d rows; want %d", len(got), len(tt.want))
+			}
+			for r := range got {
+				if got[r].Int64() != tt.want[r] {
+					t.Errorf("row %d = %v; want %d", r, got[r], tt.want[r])
+				}
+			}
+		})
+	}
+}
+
+func TestTimelinesBeyondInt64(t *testing.T) {
+	text := ".S.\n" + strings.Repeat(".^.\n^.^\n", 70)
+	m, err := ParseManifold(text)
+	if err != nil {
+		t.Fatalf("ParseManifold() error: %v", err)
+	}
+
+	if _, ok := m.sweepInt64(); ok {
+		t.Errorf("sweepInt64() ok = true; want overflow")
+	}
+	got, err := m.Timelines()
+	if err != nil {
+		t.Fatalf("Timelines() error: %v", err)
+	}
+	if want := "3541774862152233910270"; got.String() != want {
+		t.Errorf("Timelines() = %s; want %s", got, want)
+	}
+}
+
+func TestExtendedElements(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		wrap      bool
+		splits    int
+		timelines int64
+		filled    string
+	}{
+		{
+			name:      "Mirror turns the beam sideways",
+			text:      ".S.\n.\\.\n...\n",
+			splits:    0,
+			timelines: 1,
+			filled:    ".S.\n.\\-\n...",
+		},
+		{
+			name:      "Horizontal splitter hit from above",
+			text:      ".S.\n.-.\n...\n",
+			splits:    1,
+			timelines: 2,
+			filled:    ".S.\n---\n...",
+		},
+		{
+			name:      "Vertical splitter hit from the side",
+			text:      ".S..\n.\\.|\n....\n",
+			splits:    1,
+			timelines: 2,
+			filled:    ".S.|\n.\\-|\n...|",
+		},
+		{
+			name:      "Vertical splitter passes a vertical beam",
+			text:      ".S.\n.|.\n...\n",
+			splits:    0,
+			timelines: 1,
+			filled:    ".S.\n.|.\n.|.",
+		},
+		{
+			name:      "Absorber stops a timeline",
+			text:      ".S.\n.^.\n#..\n",
+			splits:    1,
+			timelines: 1,
+			filled:    ".S.\n|^|\n#.|",
+		},
+		{
+			name:      "Beam turned back up",
+			text:      "..S\n...\n\\./\n",
+			splits:    0,
+			timelines: 1,
+			filled:    "|.S\n|.|\n\\-/",
+		},
+		{
+			name:      "Crossing beams",
+			text:      ".S..\n...\\\n.\\./\n",
+			splits:    0,
+			timelines: 1,
+			filled:    ".S..\n-+-\\\n.\\-/",
+		},
+		{
+			name:      "Splitter at the edge without wrapping",
+			text:      "S..\n^..\n...\n",
+			splits:    1,
+			timelines: 2,
+			filled:    "S..\n^|.\n.|.",
+		},
+		{
+			name:      "Splitter at the edge with wrapping",
+			text:      "S..\n^..\n...\n",
+			wrap:      true,
+			splits:    1,
+			timelines: 2,
+			filled:    "S..\n^||\n.||",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := ParseManifoldWith(tt.text, ManifoldOptions{WrapEdges: tt.wrap})
+			if err != nil {
+				t.Fatalf("ParseManifoldWith() error: %v", err)
+			}
+
+			if got := m.SplitCount(); got != tt.splits {
+				t.Errorf("SplitCount() = %d; want %d", got, tt.splits)
+			}
+			if got, err := m.Timelines(); err != nil || got.Int64() != tt.timelines {
+				t.Errorf("Timelines() = %v, %v; want %d", got, err, tt.timelines)
+			}
+			if got := strings.Join(m.Filled(), "\n"); got != tt.filled {
+				t.Errorf("Filled() =\n%s\nwant\n%s", got, tt.filled)
+			}
+		})
+	}
+}
+
+func TestLoopsAreDetected(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		wrap   bool
+		splits int
+	}{
+		{
+			name:   "Mirror box",
+			text:   "..S..\n./-\\.\n.\\./.\n",
+			splits: 1,
+		},
+		{
+			name:   "Wrapping row",
+			text:   ".S.\n.-.\n",
+			wrap:   true,
+			splits: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := ParseManifoldWith(tt.text, ManifoldOptions{WrapEdges: tt.wrap})
+			if err != nil {
+				t.Fatalf("ParseManifoldWith() error: %v", err)
+			}
+
+			_, err = m.Timelines()
+			var loop *LoopError
+			if !errors.As(err, &loop) {
+				t.Fatalf("Timelines() error = %v; want *LoopError", err)
+			}
+			if got := m.SplitCount(); got != tt.splits {
+				t.Errorf("SplitCount() = %d; want %d", got, tt.splits)
+			}
+			if _, err := m.TimelinesByRow(); err == nil {
+				t.Errorf("TimelinesByRow() = nil error; want error for an extended manifold")
+			}
+		})
+	}
+}
+
+func TestPathCountMatchesRowSweep(t *testing.T) {
+	m, err := ReadManifold("input.txt")
+	if err != nil {
+		t.Fatalf("ReadManifold() error: %v", err)
+	}
+
+	want, err := m.Timelines()
+	if err != nil {
+		t.Fatalf("Timelines() error: %v", err)
+	}
+	got, err := m.countPaths()
+	if err != nil {
+		t.Fatalf("countPaths() error: %v", err)
+	}
+	if got.Cmp(want) != 0 {
+		t.Errorf("countPaths() = %v; row sweep gives %v", got, want)
+	}
+}
+
+func TestSplitterSideCellsPassedOver(t *testing.T) {
+	tests := []struct {
+		name    string
+		diagram string
+	}{
+		// An absorber and a second splitter beside the splitter do not
+		// stop or split the new beams.
+		{"absorber and splitter", ".S..\n#^^.\n....\n"},
+		// Only '^' beside the splitter, so the per-row sweep applies too.
+		{"splitters only", ".S.\n^^^\n...\n"},
+	}
+
+	for _, tt := range tests {
+		m, err := ParseManifold(tt.diagram)
+		if err != nil {
+			t.Fatalf("%s: ParseManifold() error: %v", tt.name, err)
+		}
+		got, err := m.countPaths()
+		if err != nil {
+			t.Fatalf("%s: countPaths() error: %v", tt.name, err)
+		}
+		if got.Int64() != 2 {
+			t.Errorf("%s: countPaths() = %v; want 2", tt.name, got)
+		}
+		if m.classic() {
+			rows, err := m.TimelinesByRow()
+			if err != nil {
+				t.Fatalf("%s: TimelinesByRow() error: %v", tt.name, err)
+			}
+			if last := rows[len(rows)-1]; last.Int64() != 2 {
+				t.Errorf("%s: TimelinesByRow() ends at %v; want 2", tt.name, last)
+			}
+		}
+
+		through, err := m.SplitterTimelines()
+		if err != nil {
+			t.Fatalf("%s: SplitterTimelines() error: %v", tt.name, err)
+		}
+		if len(through) != 1 || through[Pos{row: 1, col: 1}] == nil {
+			t.Errorf("%s: SplitterTimelines() = %v; want only the splitter below 'S'", tt.name, through)
+		}
+	}
+}
+
+func TestSplitterTimelines(t *testing.T) {
+	m, err := ParseManifold(example)
+	if err != nil {
+		t.Fatalf("ParseManifold() error: %v", err)
+	}
+
+	through, err := m.SplitterTimelines()
+	if err != nil {
+		t.Fatalf("SplitterTimelines() error: %v", err)
+	}
+	if len(through) != 21 {
+		t.Errorf("SplitterTimelines() has %d splitters; want 21", len(through))
+	}
+	if got := through[Pos{row: 2, col: 7}]; got == nil || got.Int64() != 40 {
+		t.Errorf("timelines through the first splitter = %v; want all 40", got)
+	}
+	if got := through[Pos{row: 6, col: 7}]; got == nil || got.Int64() != 16 {
+		t.Errorf("timelines through row 7, column 8 = %v; want 16", got)
+	}
+
+	// Walk every timeline on its own and count the splitters it hits.
+	want := make(map[Pos]int64)
+	var walk func(b beam, hits []Pos)
+	walk = func(b beam, hits []Pos) {
+		s := m.advance(b)
+		if s.split {
+			hits = append(hits, s.target)
+		}
+		for range s.exits {
+			for _, p := range hits {
+				want[p]++
+			}
+		}
+		for _, nb := range s.next {
+			walk(nb, hits[:len(hits):len(hits)])
+		}
+	}
+	walk(m.startBeam(), nil)
+
+	for p, n := range want {
+		if got := through[p]; got == nil || got.Int64() != n {
+			t.Errorf("timelines through row %d, column %d = %v; want %d", p.row+1, p.col+1, got, n)
+		}
+	}
+}
+
+func TestWriteSVG(t *testing.T) {
+	m, err := ParseManifold(".S...\n.....\n.^.^.\n..^..\n.....\n")
+	if err != nil {
+		t.Fatalf("ParseManifold() error: %v", err)
+	}
+
+	var sb strings.Builder
+	if err := m.WriteSVG(&sb); err != nil {
+		t.Fatalf("WriteSVG() error: %v", err)
+	}
+	out := sb.String()
+
+	dec := xml.NewDecoder(strings.NewReader(out))
+	for {
+		if _, err := dec.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("WriteSVG() produced invalid XML: %v", err)
+		}
+	}
+
+	if got := strings.Count(out, `class="splitter"`); got != 3 {
+		t.Errorf("SVG has %d splitters; want 3", got)
+	}
+	if got := strings.Count(out, `class="split"`); got != 2 {
+		t.Errorf("SVG has %d split points; want 2", got)
+	}
+	if !strings.Contains(out, `fill="`+idleColour+`"></polygon>`) {
+		t.Errorf("SVG does not draw the unreached splitter in %s", idleColour)
+	}
+	if !strings.Contains(out, "row 3, column 2: 3 timelines") || !strings.Contains(out, "row 4, column 3: 2 timelines") {
+		t.Errorf("SVG is missing splitter timeline titles:\n%s", out)
+	}
+}
+
+func TestWriteSVGColoursByTimelines(t *testing.T) {
+	m, err := ParseManifold(example)
+	if err != nil {
+		t.Fatalf("ParseManifold() error: %v", err)
+	}
+
+	var sb strings.Builder
+	if err := m.WriteSVG(&sb); err != nil {
+		t.Fatalf("WriteSVG() error: %v", err)
+	}
+	out := sb.String()
+
+	// Every timeline passes the first splitter, the most of any.
+	if !strings.Contains(out, `fill="#d7191c"><title>row 3, column 8: 40 timelines`) {
+		t.Errorf("first splitter is not drawn in the hot colour")
+	}
+	if got := strings.Count(out, `fill="#2c7bb6"><title>`); got == 0 {
+		t.Errorf("no splitter is drawn in the cool colour")
+	}
+	if got := strings.Count(out, `class="split"`); got != 21 {
+		t.Errorf("SVG has %d split points; want 21", got)
+	}
+}
+
+func TestWriteSVGAbsorbedSplitter(t *testing.T) {
+	// The absorbers swallow both beams of the lower left splitter, so its
+	// count is 0 while its neighbours have 1.
+	m, err := ParseManifold("...S...\n...^...\n..^.^..\n.#.#...\n.......\n")
+	if err != nil {
+		t.Fatalf("ParseManifold() error: %v", err)
+	}
+
+	var sb strings.Builder
+	if err := m.WriteSVG(&sb); err != nil {
+		t.Fatalf("WriteSVG() error: %v", err)
+	}
+	out := sb.String()
+
+	fills := regexp.MustCompile(`fill="([^"]*)"`).FindAllStringSubmatch(out, -1)
+	valid := regexp.MustCompile(`^(#[0-9a-f]{6}|none)$`)
+	for _, f := range fills {
+		if !valid.MatchString(f[1]) {
+			t.Errorf("SVG has fill %q; want #rrggbb", f[1])
+		}
+	}
+	if !strings.Contains(out, `fill="#2c7bb6"><title>row 3, column 3: 0 timelines`) {
+		t.Errorf("absorbed splitter is not drawn in the cool colour:\n%s", out)
+	}
+}
+
+func TestWriteSVGLoop(t *testing.T) {
+	m, err := ParseManifoldWith(".S.\n.-.\n", ManifoldOptions{WrapEdges: true})
+	if err != nil {
+		t.Fatalf("ParseManifoldWith() error: %v", err)
+	}
+
+	var loop *LoopError
+	if err := m.WriteSVG(io.Discard); !errors.As(err, &loop) {
+		t.Errorf("WriteSVG() error = %v; want *LoopError", err)
+	}
+}
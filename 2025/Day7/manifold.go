@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Manifold is a parsed tachyon manifold diagram. It is immutable after
+// parsing, so one value can be solved from several goroutines at once.
+//
+// Beams start at 'S' moving down and react to the cell they enter:
+//
+//	.  empty, the beam keeps its direction ('S' behaves the same)
+//	^  splitter, the beam continues from both cells beside it, left and
+//	   right of its direction of travel
+//	/  mirror, right becomes up, down becomes left and vice versa
+//	\  mirror, right becomes down, up becomes left and vice versa
+//	|  vertical splitter, a sideways beam leaves both up and down
+//	-  horizontal splitter, an upward or downward beam leaves both left and right
+//	#  absorber, the beam stops
+//
+// The beams a '^' sends out start in the cells beside it without reacting
+// to them, so a '#' or '^' right next to a splitter is passed over; the new
+// beams only react to the next cell they enter. This matches the puzzle,
+// where split beams continue from the immediate left and right.
+//
+// A beam leaving the grid ends its timeline, unless WrapEdges is set, in
+// which case leaving through the left or right edge re-enters on the other
+// side. An absorbed beam ends without a timeline.
+type Manifold struct {
+	grid  []string
+	start Pos
+	wrap  bool
+}
+
+// ManifoldOptions change how a diagram is interpreted.
+type ManifoldOptions struct {
+	WrapEdges bool
+}
+
+// ParseManifold parses a diagram with exactly one 'S' and the cells listed
+// on Manifold. Surrounding whitespace and trailing blank lines are ignored;
+// every remaining line must have the same width.
+func ParseManifold(text string) (*Manifold, error) {
+	return ParseManifoldWith(text, ManifoldOptions{})
+}
+
+// ParseManifoldWith is ParseManifold with options.
+func ParseManifoldWith(text string, opts ManifoldOptions) (*Manifold, error) {
+	lines := strings.Split(strings.TrimRight(text, "\r\n\t "), "\n")
+
+	m := &Manifold{start: Pos{row: -1, col: -1}, wrap: opts.WrapEdges}
+	for r, line := range lines {
+		line = strings.TrimSpace(line)
+		if r > 0 && len(line) != len(m.grid[0]) {
+			return nil, fmt.Errorf("line %d: width %d, want %d", r+1, len(line), len(m.grid[0]))
+		}
+
+		for c := 0; c < len(line); c++ {
+			switch line[c] {
+			case '.', '^', '/', '\\', '|', '-', '#':
+			case 'S':
+				if m.start.row >= 0 {
+					return nil, fmt.Errorf("line %d, column %d: second start 'S'", r+1, c+1)
+				}
+				m.start = Pos{row: r, col: c}
+			default:
+				return nil, fmt.Errorf("line %d, column %d: unexpected character %q", r+1, c+1, line[c])
+			}
+		}
+		m.grid = append(m.grid, line)
+	}
+
+	if m.start.row < 0 {
+		return nil, fmt.Errorf("no start 'S' in diagram")
+	}
+	return m, nil
+}
+
+// ReadManifold parses the diagram stored in filename.
+func ReadManifold(filename string) (*Manifold, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParseManifold(string(data))
+}
+
+// Start returns the position of 'S'.
+func (m *Manifold) Start() Pos {
+	return m.start
+}
+
+// classic reports whether the diagram only uses the original puzzle cells,
+// so every beam moves down and the row sweep applies.
+func (m *Manifold) classic() bool {
+	if m.wrap {
+		return false
+	}
+	for _, line := range m.grid {
+		if strings.Trim(line, ".^S") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Filled returns a copy of the diagram with every empty cell a beam crosses
+// drawn as '|' for vertical beams, '-' for sideways beams and '+' for both.
+func (m *Manifold) Filled() []string {
+	filled, _ := m.fill()
+	return filled
+}
+
+// SplitCount returns how many splitters actually split a beam.
+func (m *Manifold) SplitCount() int {
+	_, splits := m.fill()
+	return splits
+}
+
+// trace follows every beam from 'S' once per cell and direction, so it
+// stops even when beams run in loops. It returns the visited states in
+// breadth-first order and the step taken from each.
+func (m *Manifold) trace() ([]beam, map[beam]stepResult) {
+	steps := make(map[beam]stepResult)
+	var order []beam
+	queue := []beam{m.startBeam()}
+
+	for len(queue) > 0 {
+		b := queue[0]
+		queue = queue[1:]
+		if _, seen := steps[b]; seen {
+			continue
+		}
+		s := m.advance(b)
+		steps[b] = s
+		order = append(order, b)
+		queue = append(queue, s.next...)
+	}
+	return order, steps
+}
+
+func (m *Manifold) fill() ([]string, int) {
+	rows := make([][]byte, len(m.grid))
+	for r, line := range m.grid {
+		rows[r] = []byte(line)
+	}
+
+	order, steps := m.trace()
+	split := make(map[Pos]bool)
+	for _, b := range order {
+		if m.grid[b.pos.row][b.pos.col] == '.' {
+			cell := &rows[b.pos.row][b.pos.col]
+			switch {
+			case *cell == '.' && b.dir.vertical():
+				*cell = '|'
+			case *cell == '.':
+				*cell = '-'
+			case (*cell == '|') != b.dir.vertical():
+				*cell = '+'
+			}
+		}
+		if s := steps[b]; s.split {
+			split[s.target] = true
+		}
+	}
+
+	filled := make([]string, len(rows))
+	for r, row := range rows {
+		filled[r] = string(row)
+	}
+	return filled, len(split)
+}
+
+// Dir is a direction of travel.
+type Dir struct {
+	dr int
+	dc int
+}
+
+var (
+	Up    = Dir{dr: -1}
+	Down  = Dir{dr: 1}
+	Left  = Dir{dc: -1}
+	Right = Dir{dc: 1}
+)
+
+func (d Dir) vertical() bool {
+	return d.dc == 0
+}
+
+// beam is a beam standing in pos and about to move one cell in dir.
+type beam struct {
+	pos Pos
+	dir Dir
+}
+
+func (m *Manifold) startBeam() beam {
+	return beam{pos: m.start, dir: Down}
+}
+
+// neighbour returns the cell one step from p in direction d, or false when
+// that cell is outside the manifold.
+func (m *Manifold) neighbour(p Pos, d Dir) (Pos, bool) {
+	p = Pos{row: p.row + d.dr, col: p.col + d.dc}
+	width := len(m.grid[0])
+	if m.wrap {
+		p.col = (p.col + width) % width
+	}
+	if p.row < 0 || p.row >= len(m.grid) || p.col < 0 || p.col >= width {
+		return Pos{}, false
+	}
+	return p, true
+}
+
+// stepResult is the outcome of moving a beam one cell.
+type stepResult struct {
+	next   []beam // beams that stay inside the manifold
+	exits  int    // beams that left the manifold
+	target Pos    // the cell the beam moved into
+	split  bool   // whether target split the beam
+}
+
+// advance moves b one cell and applies the cell it enters. An absorbed beam
+// yields neither next beams nor exits.
+func (m *Manifold) advance(b beam) stepResult {
+	target, ok := m.neighbour(b.pos, b.dir)
+	if !ok {
+		return stepResult{exits: 1}
+	}
+	s := stepResult{target: target}
+
+	var dirs []Dir
+	switch m.grid[target.row][target.col] {
+	case '#':
+		return s
+	case '/':
+		dirs = []Dir{{dr: -b.dir.dc, dc: -b.dir.dr}}
+	case '\\':
+		dirs = []Dir{{dr: b.dir.dc, dc: b.dir.dr}}
+	case '|':
+		if b.dir.vertical() {
+			dirs = []Dir{b.dir}
+		} else {
+			dirs, s.split = []Dir{Up, Down}, true
+		}
+	case '-':
+		if b.dir.vertical() {
+			dirs, s.split = []Dir{Left, Right}, true
+		} else {
+			dirs = []Dir{b.dir}
+		}
+	case '^':
+		// The new beams appear in the cells on either side of the
+		// splitter and keep the original direction. Those cells are not
+		// applied; see Manifold.
+		s.split = true
+		for _, side := range []Dir{{dr: -b.dir.dc, dc: -b.dir.dr}, {dr: b.dir.dc, dc: b.dir.dr}} {
+			if p, ok := m.neighbour(target, side); ok {
+				s.next = append(s.next, beam{pos: p, dir: b.dir})
+			} else {
+				s.exits++
+			}
+		}
+		return s
+	default:
+		dirs = []Dir{b.dir}
+	}
+
+	for _, d := range dirs {
+		s.next = append(s.next, beam{pos: target, dir: d})
+	}
+	return s
+}
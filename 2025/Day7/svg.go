@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// svgCell is the edge length of one diagram cell in SVG units.
+const svgCell = 12
+
+// Colours used by WriteSVG. Splitters are shaded from coolColour (fewest
+// timelines) to hotColour (most timelines) on a logarithmic scale.
+var (
+	coolColour = [3]float64{0x2c, 0x7b, 0xb6}
+	hotColour  = [3]float64{0xd7, 0x19, 0x1c}
+)
+
+const (
+	gridColour     = "#e4e4e4"
+	beamColour     = "#f2a900"
+	elementColour  = "#333333"
+	idleColour     = "#b0b0b0"
+	startColour    = "#2e9e44"
+	splitDotColour = "#ffffff"
+)
+
+// WriteSVG draws the manifold as an SVG image: the cell grid, every
+// element, every beam path and a dot on each split point. Splitters are
+// coloured by how many timelines pass through them; splitters no beam
+// reaches are grey. It fails with a *LoopError when timelines cannot be
+// counted.
+func (m *Manifold) WriteSVG(w io.Writer) error {
+	through, err := m.SplitterTimelines()
+	if err != nil {
+		return err
+	}
+
+	rows, cols := len(m.grid), len(m.grid[0])
+	width, height := cols*svgCell, rows*svgCell
+	lo, hi := countRange(through)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		width, height+2*svgCell, width, height+2*svgCell)
+	fmt.Fprintf(&sb, `<rect width="%d" height="%d" fill="#ffffff"/>`+"\n", width, height+2*svgCell)
+
+	// grid
+	fmt.Fprintf(&sb, `<g stroke="%s" stroke-width="0.5">`+"\n", gridColour)
+	for r := 0; r <= rows; r++ {
+		fmt.Fprintf(&sb, `<line x1="0" y1="%d" x2="%d" y2="%d"/>`+"\n", r*svgCell, width, r*svgCell)
+	}
+	for c := 0; c <= cols; c++ {
+		fmt.Fprintf(&sb, `<line x1="%d" y1="0" x2="%d" y2="%d"/>`+"\n", c*svgCell, c*svgCell, height)
+	}
+	sb.WriteString("</g>\n")
+
+	// beams
+	fmt.Fprintf(&sb, `<g stroke="%s" stroke-width="2" stroke-linecap="round">`+"\n", beamColour)
+	for _, seg := range m.beamSegments() {
+		fmt.Fprintf(&sb, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f"/>`+"\n", seg[0], seg[1], seg[2], seg[3])
+	}
+	sb.WriteString("</g>\n")
+
+	// elements
+	for r, line := range m.grid {
+		for c := 0; c < len(line); c++ {
+			m.writeElement(&sb, Pos{row: r, col: c}, through, lo, hi)
+		}
+	}
+
+	// split points
+	splits := make([]Pos, 0, len(through))
+	for p := range through {
+		splits = append(splits, p)
+	}
+	sort.Slice(splits, func(i, j int) bool {
+		if splits[i].row != splits[j].row {
+			return splits[i].row < splits[j].row
+		}
+		return splits[i].col < splits[j].col
+	})
+	fmt.Fprintf(&sb, `<g fill="%s" stroke="%s" stroke-width="0.5">`+"\n", splitDotColour, elementColour)
+	for _, p := range splits {
+		x, y := cellCentre(p)
+		fmt.Fprintf(&sb, `<circle class="split" cx="%.1f" cy="%.1f" r="%.1f"/>`+"\n", x, y, svgCell*0.15)
+	}
+	sb.WriteString("</g>\n")
+
+	fmt.Fprintf(&sb, `<text x="2" y="%d" font-family="monospace" font-size="%d" fill="%s">timelines per splitter: %s to %s</text>`+"\n",
+		height+svgCell+svgCell/2, svgCell, elementColour, lo, hi)
+	sb.WriteString("</svg>\n")
+
+	_, err = io.WriteString(w, sb.String())
+	return err
+}
+
+func (m *Manifold) writeElement(sb *strings.Builder, p Pos, through map[Pos]*big.Int, lo, hi *big.Int) {
+	x0, y0 := float64(p.col*svgCell), float64(p.row*svgCell)
+	x, y := cellCentre(p)
+	h := float64(svgCell) / 2
+
+	fill := idleColour
+	title := ""
+	if n, ok := through[p]; ok {
+		fill = countColour(n, lo, hi)
+		title = fmt.Sprintf("<title>row %d, column %d: %s timelines</title>", p.row+1, p.col+1, n)
+	}
+
+	switch m.grid[p.row][p.col] {
+	case '^':
+		fmt.Fprintf(sb, `<polygon class="splitter" points="%.1f,%.1f %.1f,%.1f %.1f,%.1f" fill="%s">%s</polygon>`+"\n",
+			x, y0+1, x0+1, y0+svgCell-1, x0+svgCell-1, y0+svgCell-1, fill, title)
+	case '|':
+		fmt.Fprintf(sb, `<rect class="splitter" x="%.1f" y="%.1f" width="%.1f" height="%d" fill="%s">%s</rect>`+"\n",
+			x-1.5, y0, 3.0, svgCell, fill, title)
+	case '-':
+		fmt.Fprintf(sb, `<rect class="splitter" x="%.1f" y="%.1f" width="%d" height="%.1f" fill="%s">%s</rect>`+"\n",
+			x0, y-1.5, svgCell, 3.0, fill, title)
+	case '/':
+		fmt.Fprintf(sb, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="2"/>`+"\n",
+			x-h, y+h, x+h, y-h, elementColour)
+	case '\\':
+		fmt.Fprintf(sb, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="2"/>`+"\n",
+			x-h, y-h, x+h, y+h, elementColour)
+	case '#':
+		fmt.Fprintf(sb, `<rect x="%.1f" y="%.1f" width="%d" height="%d" fill="%s"/>`+"\n",
+			x0, y0, svgCell, svgCell, elementColour)
+	case 'S':
+		fmt.Fprintf(sb, `<circle cx="%.1f" cy="%.1f" r="%.1f" fill="%s"/>`+"\n", x, y, h-1, startColour)
+	}
+}
+
+// beamSegments returns one line per cell step any beam takes, including
+// the short hops from a '^' to the cells beside it. Steps that leave the
+// grid or wrap around it are drawn up to the edge.
+func (m *Manifold) beamSegments() [][4]float64 {
+	type hop struct {
+		from Pos
+		dir  Dir
+	}
+	seen := make(map[hop]bool)
+	var segs [][4]float64
+
+	add := func(from Pos, d Dir) {
+		if seen[hop{from, d}] {
+			return
+		}
+		seen[hop{from, d}] = true
+
+		x1, y1 := cellCentre(from)
+		to, ok := m.neighbour(from, d)
+		if !ok || abs(to.col-from.col) > 1 {
+			x2 := x1 + float64(d.dc*svgCell)/2
+			y2 := y1 + float64(d.dr*svgCell)/2
+			segs = append(segs, [4]float64{x1, y1, x2, y2})
+			if ok {
+				x3, y3 := cellCentre(to)
+				segs = append(segs, [4]float64{x3 - float64(d.dc*svgCell)/2, y3 - float64(d.dr*svgCell)/2, x3, y3})
+			}
+			return
+		}
+		x2, y2 := cellCentre(to)
+		segs = append(segs, [4]float64{x1, y1, x2, y2})
+	}
+
+	order, steps := m.trace()
+	for _, b := range order {
+		add(b.pos, b.dir)
+		s := steps[b]
+		if s.split && m.grid[s.target.row][s.target.col] == '^' {
+			add(s.target, Dir{dr: -b.dir.dc, dc: -b.dir.dr})
+			add(s.target, Dir{dr: b.dir.dc, dc: b.dir.dr})
+		}
+	}
+	return segs
+}
+
+func cellCentre(p Pos) (float64, float64) {
+	return float64(p.col*svgCell) + svgCell/2.0, float64(p.row*svgCell) + svgCell/2.0
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// countRange returns the smallest and largest count, or 0 and 0 when there
+// are none.
+func countRange(counts map[Pos]*big.Int) (*big.Int, *big.Int) {
+	lo, hi := new(big.Int), new(big.Int)
+	first := true
+	for _, n := range counts {
+		if first || n.Cmp(lo) < 0 {
+			lo.Set(n)
+		}
+		if first || n.Cmp(hi) > 0 {
+			hi.Set(n)
+		}
+		first = false
+	}
+	return lo, hi
+}
+
+// countColour maps n between lo and hi to a colour between coolColour and
+// hotColour. The scale is logarithmic because counts double at every split,
+// and shifted by one so that splitters whose beams are all absorbed, with a
+// count of 0, get the cool end instead of minus infinity.
+func countColour(n, lo, hi *big.Int) string {
+	t := 1.0
+	if span := logCount(hi) - logCount(lo); span > 0 {
+		t = min(1, max(0, (logCount(n)-logCount(lo))/span))
+	}
+
+	var rgb [3]int
+	for i := range rgb {
+		rgb[i] = int(math.Round(coolColour[i] + t*(hotColour[i]-coolColour[i])))
+	}
+	return fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2])
+}
+
+// logCount returns log(n+1) for a count n >= 0.
+func logCount(n *big.Int) float64 {
+	return bigLog(new(big.Int).Add(n, big.NewInt(1)))
+}
+
+// bigLog returns the natural logarithm of n, which must be positive. It
+// stays finite for numbers beyond the float64 range.
+func bigLog(n *big.Int) float64 {
+	if n.BitLen() < 1000 {
+		f, _ := new(big.Float).SetInt(n).Float64()
+		return math.Log(f)
+	}
+	shift := n.BitLen() - 64
+	f, _ := new(big.Float).SetInt(new(big.Int).Rsh(n, uint(shift))).Float64()
+	return math.Log(f) + float64(shift)*math.Ln2
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// LoopError reports a beam that can come back to a cell it already passed
+// in the same direction, so a particle could travel forever.
+type LoopError struct {
+	Pos Pos
+	Dir Dir
+}
+
+func (e *LoopError) Error() string {
+	return fmt.Sprintf("beam loops through row %d, column %d", e.Pos.row+1, e.Pos.col+1)
+}
+
+var errNotClassic = errors.New("per-row timelines need a manifold of only '.', '^' and 'S' without wrapping")
+
+// Timelines returns the number of distinct paths a single particle can take
+// from 'S' until it leaves the manifold, choosing one way at every splitter.
+// Absorbed particles do not count. It returns a *LoopError when a particle
+// could travel forever.
+func (m *Manifold) Timelines() (*big.Int, error) {
+	if !m.classic() {
+		return m.countPaths()
+	}
+	rows, err := m.TimelinesByRow()
+	if err != nil {
+		return nil, err
+	}
+	return rows[len(rows)-1], nil
+}
+
+// beamGraph walks every beam state reachable from 'S' with an explicit
+// stack, so tall diagrams cannot overflow the call stack. It returns the
+// states in post-order, each after all states it leads to, together with
+// the step taken from each. A state found again on the current path is a
+// loop.
+func (m *Manifold) beamGraph() ([]beam, map[beam]stepResult, error) {
+	type frame struct {
+		b beam
+		i int
+	}
+
+	steps := make(map[beam]stepResult)
+	onPath := make(map[beam]bool)
+	var order []beam
+	push := func(stack []frame, b beam) []frame {
+		onPath[b] = true
+		steps[b] = m.advance(b)
+		return append(stack, frame{b: b})
+	}
+
+	stack := push(nil, m.startBeam())
+	for len(stack) > 0 {
+		f := &stack[len(stack)-1]
+		next := steps[f.b].next
+		if f.i < len(next) {
+			nb := next[f.i]
+			f.i++
+			if onPath[nb] {
+				return nil, nil, &LoopError{Pos: nb.pos, Dir: nb.dir}
+			}
+			if _, done := steps[nb]; !done {
+				stack = push(stack, nb)
+			}
+			continue
+		}
+
+		order = append(order, f.b)
+		delete(onPath, f.b)
+		stack = stack[:len(stack)-1]
+	}
+	return order, steps, nil
+}
+
+// countPaths counts timelines over the graph of beam states.
+func (m *Manifold) countPaths() (*big.Int, error) {
+	order, steps, err := m.beamGraph()
+	if err != nil {
+		return nil, err
+	}
+	return pathWays(order, steps)[m.startBeam()], nil
+}
+
+// pathWays returns, for every beam state, the number of ways a particle in
+// that state can go on to leave the manifold. order must be the post-order
+// from beamGraph.
+func pathWays(order []beam, steps map[beam]stepResult) map[beam]*big.Int {
+	ways := make(map[beam]*big.Int, len(order))
+	for _, b := range order {
+		s := steps[b]
+		w := big.NewInt(int64(s.exits))
+		for _, nb := range s.next {
+			w.Add(w, ways[nb])
+		}
+		ways[b] = w
+	}
+	return ways
+}
+
+// SplitterTimelines returns, for every splitter a beam reaches, how many
+// timelines pass through it: the ways to arrive at the splitter times the
+// ways to leave the manifold from there. A splitter hit from several
+// directions adds up all of them, and a timeline that hits the same
+// splitter twice counts twice.
+func (m *Manifold) SplitterTimelines() (map[Pos]*big.Int, error) {
+	order, steps, err := m.beamGraph()
+	if err != nil {
+		return nil, err
+	}
+	ways := pathWays(order, steps)
+
+	// Walking the post-order backwards visits every state before the
+	// states it leads to.
+	reach := map[beam]*big.Int{m.startBeam(): big.NewInt(1)}
+	through := make(map[Pos]*big.Int)
+	for i := len(order) - 1; i >= 0; i-- {
+		b := order[i]
+		s := steps[b]
+		if s.split {
+			if through[s.target] == nil {
+				through[s.target] = new(big.Int)
+			}
+			through[s.target].Add(through[s.target], new(big.Int).Mul(reach[b], ways[b]))
+		}
+		for _, nb := range s.next {
+			if reach[nb] == nil {
+				reach[nb] = new(big.Int)
+			}
+			reach[nb].Add(reach[nb], reach[b])
+		}
+	}
+	return through, nil
+}
+
+// TimelinesByRow returns, for every row of the diagram, how many timelines
+// exist once the particle has reached that row. Timelines that left through
+// a side edge further up are still counted, so the last entry equals
+// Timelines and the ratio between neighbouring entries shows where splitters
+// multiply the count. Rows above 'S' hold zero.
+//
+// The diagram is swept one row at a time keeping only a per-column count
+// vector. Counts stay in int64 while they fit; on the first overflow the
+// sweep is redone with math/big. Only manifolds where every beam moves down
+// can be swept this way.
+func (m *Manifold) TimelinesByRow() ([]*big.Int, error) {
+	if !m.classic() {
+		return nil, errNotClassic
+	}
+	if totals, ok := m.sweepInt64(); ok {
+		out := make([]*big.Int, len(totals))
+		for r, v := range totals {
+			out[r] = big.NewInt(v)
+		}
+		return out, nil
+	}
+	return m.sweepBig(), nil
+}
+
+func addInt64(a, b int64) (int64, bool) {
+	if a > math.MaxInt64-b {
+		return 0, false
+	}
+	return a + b, true
+}
+
+func (m *Manifold) sweepInt64() ([]int64, bool) {
+	width := len(m.grid[0])
+	totals := make([]int64, len(m.grid))
+	cur := make([]int64, width)
+	next := make([]int64, width)
+	cur[m.start.col] = 1
+	totals[m.start.row] = 1
+	var exited int64
+
+	for r := m.start.row + 1; r < len(m.grid); r++ {
+		clear(next)
+		for c, n := range cur {
+			if n == 0 {
+				continue
+			}
+			var ok bool
+			if m.grid[r][c] != '^' {
+				if next[c], ok = addInt64(next[c], n); !ok {
+					return nil, false
+				}
+				continue
+			}
+			for _, t := range [2]int{c - 1, c + 1} {
+				if t < 0 || t >= width {
+					exited, ok = addInt64(exited, n)
+				} else {
+					next[t], ok = addInt64(next[t], n)
+				}
+				if !ok {
+					return nil, false
+				}
+			}
+		}
+		cur, next = next, cur
+
+		total := exited
+		for _, n := range cur {
+			var ok bool
+			if total, ok = addInt64(total, n); !ok {
+				return nil, false
+			}
+		}
+		totals[r] = total
+	}
+	return totals, true
+}
+
+func (m *Manifold) sweepBig() []*big.Int {
+	width := len(m.grid[0])
+	totals := make([]*big.Int, len(m.grid))
+	for r := range totals {
+		totals[r] = new(big.Int)
+	}
+	cur := make([]*big.Int, width)
+	next := make([]*big.Int, width)
+	for c := range width {
+		cur[c], next[c] = new(big.Int), new(big.Int)
+	}
+	cur[m.start.col].SetInt64(1)
+	totals[m.start.row].SetInt64(1)
+	exited := new(big.Int)
+
+	for r := m.start.row + 1; r < len(m.grid); r++ {
+		for _, n := range next {
+			n.SetInt64(0)
+		}
+		for c, n := range cur {
+			if n.Sign() == 0 {
+				continue
+			}
+			if m.grid[r][c] != '^' {
+				next[c].Add(next[c], n)
+				continue
+			}
+			for _, t := range [2]int{c - 1, c + 1} {
+				if t < 0 || t >= width {
+					exited.Add(exited, n)
+				} else {
+					next[t].Add(next[t], n)
+				}
+			}
+		}
+		cur, next = next, cur
+
+		totals[r].Set(exited)
+		for _, n := range cur {
+			totals[r].Add(totals[r], n)
+		}
+	}
+	return totals
+}
@@ -0,0 +1,83 @@
+package main
+
+import "sort"
+
+// DisjointSet tracks which junction boxes share a circuit. Boxes are
+// identified by their index in the input. It uses path compression and
+// union by size, and keeps the number of circuits and each circuit's size
+// up to date on every union.
+type DisjointSet struct {
+	parent []int
+	size   []int
+	count  int
+}
+
+// NewDisjointSet returns n boxes, each in a circuit of its own.
+func NewDisjointSet(n int) *DisjointSet {
+	d := &DisjointSet{
+		parent: make([]int, n),
+		size:   make([]int, n),
+		count:  n,
+	}
+	for i := range d.parent {
+		d.parent[i] = i
+		d.size[i] = 1
+	}
+	return d
+}
+
+// Find returns the representative box of x's circuit.
+func (d *DisjointSet) Find(x int) int {
+	root := x
+	for d.parent[root] != root {
+		root = d.parent[root]
+	}
+	// Point every box on the way directly at the root.
+	for d.parent[x] != root {
+		d.parent[x], x = root, d.parent[x]
+	}
+	return root
+}
+
+// Union joins the circuits of a and b. It reports false when they were
+// already in the same circuit.
+func (d *DisjointSet) Union(a, b int) bool {
+	ra, rb := d.Find(a), d.Find(b)
+	if ra == rb {
+		return false
+	}
+	if d.size[ra] < d.size[rb] {
+		ra, rb = rb, ra
+	}
+	d.parent[rb] = ra
+	d.size[ra] += d.size[rb]
+	d.count--
+	return true
+}
+
+// Connected reports whether a and b are in the same circuit.
+func (d *DisjointSet) Connected(a, b int) bool {
+	return d.Find(a) == d.Find(b)
+}
+
+// Count returns the number of circuits, counting lone boxes.
+func (d *DisjointSet) Count() int {
+	return d.count
+}
+
+// Size returns the number of boxes in x's circuit.
+func (d *DisjointSet) Size(x int) int {
+	return d.size[d.Find(x)]
+}
+
+// Sizes returns the size of every circuit, largest first.
+func (d *DisjointSet) Sizes() []int {
+	sizes := make([]int, 0, d.count)
+	for i, p := range d.parent {
+		if p == i {
+			sizes = append(sizes, d.size[i])
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(sizes)))
+	return sizes
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Wiring records connections made in order and the circuits they formed.
+type Wiring struct {
+	boxes    []Box
+	metric   Metric
+	steps    []Connection
+	merged   []bool
+	circuits *DisjointSet
+}
+
+// wire makes conns in order on a fresh set of circuits.
+func wire(boxes []Box, metric Metric, conns []Connection) *Wiring {
+	w := &Wiring{
+		boxes:    boxes,
+		metric:   metric,
+		steps:    conns,
+		merged:   make([]bool, len(conns)),
+		circuits: NewDisjointSet(len(boxes)),
+	}
+	for i, c := range conns {
+		w.merged[i] = w.circuits.Union(c.a, c.b)
+	}
+	return w
+}
+
+// wireShortest makes the k shortest connections, like part 1.
+func wireShortest(boxes []Box, k int, metric Metric) *Wiring {
+	return wire(boxes, metric, shortestConnections(boxes, k, metric))
+}
+
+// wireUntilConnected makes only the connections that join two circuits,
+// shortest first, until a single circuit is left, like part 2. Those are
+// exactly the spanning tree edges; connections inside an existing circuit
+// change nothing and are left out.
+func wireUntilConnected(boxes []Box, metric Metric) *Wiring {
+	tree := minimumSpanningTree(boxes, metric)
+	sort.Slice(tree, func(i, j int) bool {
+		return pairLess(tree[i].dist, tree[i].a, tree[i].b, tree[j].dist, tree[j].a, tree[j].b)
+	})
+	return wire(boxes, metric, tree)
+}
+
+// Circuits returns the box indices of every circuit, largest circuit first
+// and ties by lowest box index. Indices within a circuit are ascending.
+func (w *Wiring) Circuits() [][]int {
+	byRoot := make(map[int][]int)
+	var roots []int
+	for i := range w.boxes {
+		r := w.circuits.Find(i)
+		if byRoot[r] == nil {
+			roots = append(roots, r)
+		}
+		byRoot[r] = append(byRoot[r], i)
+	}
+
+	circuits := make([][]int, 0, len(roots))
+	for _, r := range roots {
+		circuits = append(circuits, byRoot[r])
+	}
+	sort.SliceStable(circuits, func(i, j int) bool {
+		return len(circuits[i]) > len(circuits[j])
+	})
+	return circuits
+}
+
+type jsonBox struct {
+	Index  int   `json:"index"`
+	Coords []int `json:"coords"`
+}
+
+type jsonConnection struct {
+	Step     int     `json:"step"`
+	A        jsonBox `json:"a"`
+	B        jsonBox `json:"b"`
+	Dist     int64   `json:"dist"`
+	Distance float64 `json:"distance"`
+	Merged   bool    `json:"merged"`
+}
+
+type jsonCircuit struct {
+	Size        int              `json:"size"`
+	Boxes       []jsonBox        `json:"boxes"`
+	Connections []jsonConnection `json:"connections"`
+}
+
+type jsonWiring struct {
+	Metric      string           `json:"metric"`
+	Connections []jsonConnection `json:"connections"`
+	Circuits    []jsonCircuit    `json:"circuits"`
+	Last        *jsonConnection  `json:"last,omitempty"`
+}
+
+func (w *Wiring) jsonConnection(i int) jsonConnection {
+	c := w.steps[i]
+	return jsonConnection{
+		Step:     i + 1,
+		A:        jsonBox{Index: c.a, Coords: c.boxA},
+		B:        jsonBox{Index: c.b, Coords: c.boxB},
+		Dist:     c.dist,
+		Distance: w.metric.Length(c.dist),
+		Merged:   w.merged[i],
+	}
+}
+
+// WriteJSON writes every connection in the order it was made, followed by
+// each circuit with its member boxes and its own connections in order.
+// dist is the exact metric value and distance the measured length; merged
+// tells whether the connection joined two circuits. last is the final
+// connection made.
+func (w *Wiring) WriteJSON(out io.Writer) error {
+	doc := jsonWiring{
+		Metric:      w.metric.String(),
+		Connections: make([]jsonConnection, len(w.steps)),
+	}
+	for i := range w.steps {
+		doc.Connections[i] = w.jsonConnection(i)
+	}
+	if len(w.steps) > 0 {
+		last := doc.Connections[len(w.steps)-1]
+		doc.Last = &last
+	}
+
+	circuitOf := make(map[int]int)
+	for id, members := range w.Circuits() {
+		c := jsonCircuit{Size: len(members), Boxes: make([]jsonBox, len(members)), Connections: []jsonConnection{}}
+		for k, i := range members {
+			c.Boxes[k] = jsonBox{Index: i, Coords: w.boxes[i]}
+			circuitOf[i] = id
+		}
+		doc.Circuits = append(doc.Circuits, c)
+	}
+	for i, c := range w.steps {
+		id := circuitOf[c.a]
+		doc.Circuits[id].Connections = append(doc.Circuits[id].Connections, doc.Connections[i])
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// WriteDOT writes the connection forest as a Graphviz graph. Every box is
+// a node labelled with its coordinates and grouped by circuit. Connections
+// that joined two circuits are solid edges labelled with their step and
+// distance, connections inside a circuit are dashed, and the last
+// connection is drawn in red.
+func (w *Wiring) WriteDOT(out io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("graph circuits {\n")
+	sb.WriteString("  node [shape=box, fontname=\"monospace\"];\n")
+
+	for id, members := range w.Circuits() {
+		fmt.Fprintf(&sb, "  subgraph cluster_%d {\n", id)
+		fmt.Fprintf(&sb, "    label=\"circuit %d (%d boxes)\";\n", id+1, len(members))
+		for _, i := range members {
+			fmt.Fprintf(&sb, "    b%d [label=\"%s\"];\n", i, boxLabel(w.boxes[i]))
+		}
+		sb.WriteString("  }\n")
+	}
+
+	for i, c := range w.steps {
+		attrs := []string{fmt.Sprintf("label=\"#%d %.4g\"", i+1, w.metric.Length(c.dist))}
+		if !w.merged[i] {
+			attrs = append(attrs, "style=dashed", "color=gray")
+		}
+		if i == len(w.steps)-1 {
+			attrs = append(attrs, "color=red", "penwidth=2")
+		}
+		fmt.Fprintf(&sb, "  b%d -- b%d [%s];\n", c.a, c.b, strings.Join(attrs, ", "))
+	}
+	sb.WriteString("}\n")
+
+	_, err := io.WriteString(out, sb.String())
+	return err
+}
+
+func boxLabel(b Box) string {
+	parts := make([]string, len(b))
+	for i, v := range b {
+		parts[i] = fmt.Sprint(v)
+	}
+	return strings.Join(parts, ",")
+}
@@ -2,39 +2,46 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
+	"io"
 	"log"
-	"math"
 	"os"
-	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/google/btree"
 )
 
-// Box represents a junction box's position in 3D space.
-type Box struct {
-	x, y, z int
-}
+// Box represents a junction box's position, one coordinate per axis.
+// The puzzle uses three axes, but any dimension works as long as every box
+// in a playground has the same one.
+type Box []int
 
 // Connection represents a potential string-of-lights connection
-// between two junction boxes, along with its straight-line distance.
+// between two junction boxes, along with its distance.
+// a and b are the boxes' indices in the input and dist is the exact
+// distance under the playground's Metric, used for ordering.
 type Connection struct {
 	boxA, boxB Box
-	distance   float64
+	a, b       int
+	dist       int64
 }
 
 // ConnectionItem is the type stored in the B-tree, ordered by distance.
 type ConnectionItem Connection
 
 // Less implements btree.Item and sorts connections by ascending distance.
+// Pairs at the same distance are ordered by their box indices, so every
+// pair is a distinct key and ReplaceOrInsert never drops one.
 func (c ConnectionItem) Less(other btree.Item) bool {
-	return c.distance < other.(ConnectionItem).distance
+	o := other.(ConnectionItem)
+	return pairLess(c.dist, c.a, c.b, o.dist, o.a, o.b)
 }
 
-// readBoxesFromFile reads junction box coordinates (X,Y,Z per line)
-// from the given file and returns the list of boxes.
+// readBoxesFromFile reads junction box coordinates (comma-separated,
+// one box per line) from the given file and returns the list of boxes.
 func readBoxesFromFile(filename string) []Box {
 	file, err := os.Open(filename)
 	if err != nil {
@@ -42,79 +49,68 @@ func readBoxesFromFile(filename string) []Box {
 	}
 	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
+	boxes, err := parseBoxes(file)
+	if err != nil {
+		log.Fatalf("error reading %s: %v", filename, err)
+	}
+	return boxes
+}
+
+// parseBoxes reads one box per line as comma-separated integers, such as
+// "162,817,812" or "4,-2". Blank lines are skipped; any other line that is
+// not a list of integers, or that has a different number of axes than the
+// first box, is an error naming the line.
+func parseBoxes(r io.Reader) ([]Box, error) {
+	scanner := bufio.NewScanner(r)
 	var boxes []Box
+	lineNo := 0
 
 	for scanner.Scan() {
+		lineNo++
 		line := strings.TrimSpace(scanner.Text())
-		var b Box
-		// Expect "x,y,z".
-		if _, err := fmt.Sscanf(line, "%d,%d,%d", &b.x, &b.y, &b.z); err == nil {
-			boxes = append(boxes, b)
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Split(line, ",")
+		b := make(Box, len(fields))
+		for i, f := range fields {
+			v, err := strconv.Atoi(strings.TrimSpace(f))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid coordinate %q", lineNo, f)
+			}
+			b[i] = v
 		}
+		if len(boxes) > 0 && len(b) != len(boxes[0]) {
+			return nil, fmt.Errorf("line %d: %d coordinates, want %d", lineNo, len(b), len(boxes[0]))
+		}
+		boxes = append(boxes, b)
 	}
 
 	if err := scanner.Err(); err != nil {
-		log.Fatalf("error reading %s: %v", filename, err)
+		return nil, err
 	}
-
-	return boxes
-}
-
-// boxDistance returns the 3D Euclidean distance between two boxes.
-func boxDistance(a, b Box) float64 {
-	dx := float64(a.x - b.x)
-	dy := float64(a.y - b.y)
-	dz := float64(a.z - b.z)
-	return math.Sqrt(dx*dx + dy*dy + dz*dz)
+	return boxes, nil
 }
 
 // buildConnectionTree builds a B-tree containing all possible connections
-// between pairs of boxes, sorted by straight-line distance.
+// between pairs of boxes, sorted by distance under metric.
 //
 // Only unique pairs (i < j) are considered, so each pair of boxes appears once.
-func buildConnectionTree(boxes []Box) *btree.BTree {
+// This needs memory for every pair; main uses the k-d tree queries in
+// nearest.go instead and the tests keep this as the reference.
+func buildConnectionTree(boxes []Box, metric Metric) *btree.BTree {
 	tree := btree.New(32)
 
 	for i := 0; i < len(boxes); i++ {
 		for j := i + 1; j < len(boxes); j++ {
-			conn := ConnectionItem{
-				boxA:     boxes[i],
-				boxB:     boxes[j],
-				distance: boxDistance(boxes[i], boxes[j]),
-			}
-			tree.ReplaceOrInsert(conn)
+			tree.ReplaceOrInsert(ConnectionItem(newConnection(boxes, i, j, metric)))
 		}
 	}
 
 	return tree
 }
 
-// allBoxesInSingleCircuit reports whether all boxes belong to a single circuit,
-// according to the circuit IDs stored in circuitByBox.
-func allBoxesInSingleCircuit(circuitByBox map[Box]int, totalBoxes int) bool {
-	// If some boxes haven't appeared in the map yet, they are still isolated.
-	if len(circuitByBox) != totalBoxes {
-		return false
-	}
-
-	first := true
-	var ref int
-
-	for _, circuitID := range circuitByBox {
-		if first {
-			ref = circuitID
-			first = false
-			continue
-		}
-		if circuitID != ref {
-			return false
-		}
-	}
-
-	return true
-}
-
 // simulateConnections walks the sorted list of possible connections
 // (shortest first) and simulates plugging in strings of lights.
 //
@@ -125,120 +121,85 @@ func allBoxesInSingleCircuit(circuitByBox map[Box]int, totalBoxes int) bool {
 //   - if   -1: keep connecting until all boxes are in one circuit
 //
 // It returns:
-// - a map assigning each box to a "circuit ID"
+// - the circuits, as a disjoint set over box indices
 // - the last ConnectionItem that was processed
-func simulateConnections(totalBoxes int, tree *btree.BTree, maxConnections int) (map[Box]int, ConnectionItem) {
-	circuitByBox := make(map[Box]int) // box -> circuit ID
-	nextCircuitID := 0
+func simulateConnections(totalBoxes int, tree *btree.BTree, maxConnections int) (*DisjointSet, ConnectionItem) {
+	circuits := NewDisjointSet(totalBoxes)
+	connections := 0
 	var lastConnection ConnectionItem
 
 	tree.Ascend(func(item btree.Item) bool {
 		// Stop condition for part 2: all boxes are in a single circuit.
-		if maxConnections == -1 && allBoxesInSingleCircuit(circuitByBox, totalBoxes) {
+		if maxConnections == -1 && circuits.Count() == 1 {
 			return false
 		}
 
 		// Stop condition for part 1: we've considered maxConnections shortest pairs.
-		if maxConnections != -1 && nextCircuitID == maxConnections {
+		if maxConnections != -1 && connections == maxConnections {
 			return false
 		}
 
 		conn := item.(ConnectionItem)
 		lastConnection = conn
-		nextCircuitID++
-
-		a, b := conn.boxA, conn.boxB
-		circuitA, hasA := circuitByBox[a]
-		circuitB, hasB := circuitByBox[b]
-
-		switch {
-		case !hasA && !hasB:
-			// Both boxes are new: start a brand new circuit.
-			circuitByBox[a] = nextCircuitID
-			circuitByBox[b] = nextCircuitID
-
-		case hasA && !hasB:
-			// Box B joins A's circuit.
-			circuitByBox[b] = circuitA
-
-		case !hasA && hasB:
-			// Box A joins B's circuit.
-			circuitByBox[a] = circuitB
-
-		default:
-			// Both boxes already belong to circuits:
-			// merge the two circuits into a new circuit ID.
-			for box, cid := range circuitByBox {
-				if cid == circuitA || cid == circuitB {
-					circuitByBox[box] = nextCircuitID
-				}
-			}
-		}
+		connections++
+
+		// Connecting two boxes that already share a circuit still uses
+		// up a connection, it just changes nothing.
+		circuits.Union(conn.a, conn.b)
 
 		return true
 	})
 
-	return circuitByBox, lastConnection
+	return circuits, lastConnection
 }
 
-// sortedCircuitSizes takes the circuit assignments and returns a slice of
-// circuit sizes, sorted from largest to smallest.
-func sortedCircuitSizes(circuitByBox map[Box]int) []int {
-	// Count how many boxes belong to each circuit ID.
-	boxesPerCircuit := make(map[int]int)
-	for _, circuitID := range circuitByBox {
-		boxesPerCircuit[circuitID]++
-	}
-
-	// Convert to slice of (circuitID, size) pairs for sorting.
-	type circuitSize struct {
-		id   int
-		size int
-	}
-
-	pairs := make([]circuitSize, 0, len(boxesPerCircuit))
-	for id, size := range boxesPerCircuit {
-		pairs = append(pairs, circuitSize{id: id, size: size})
-	}
-
-	// Sort by size descending (largest circuits first).
-	sort.Slice(pairs, func(i, j int) bool {
-		return pairs[i].size > pairs[j].size
-	})
+// sortedCircuitSizes returns the size of every circuit, sorted from
+// largest to smallest.
+func sortedCircuitSizes(circuits *DisjointSet) []int {
+	return circuits.Sizes()
+}
 
-	// Extract just sizes in sorted order.
-	sizes := make([]int, len(pairs))
-	for i, p := range pairs {
-		sizes[i] = p.size
+// connectShortest connects the k shortest pairs and returns the circuits.
+func connectShortest(boxes []Box, k int, metric Metric) *DisjointSet {
+	circuits := NewDisjointSet(len(boxes))
+	for _, c := range shortestConnections(boxes, k, metric) {
+		circuits.Union(c.a, c.b)
 	}
-
-	return sizes
+	return circuits
 }
 
 func main() {
+	metricName := flag.String("metric", SquaredEuclidean.String(), "distance used to rank connections: squared-euclidean, manhattan or chebyshev")
+	inputFile := flag.String("input", "./input.txt", "junction box layout")
+	jsonFile := flag.String("json", "", "write the circuits and the connections made as JSON to this file")
+	dotFile := flag.String("dot", "", "write the connection forest as Graphviz DOT to this file")
+	untilConnected := flag.Bool("until-connected", false, "export the part 2 wiring instead of the 1000 shortest connections")
+	flag.Parse()
+
+	metric, err := ParseMetric(*metricName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	start := time.Now()
 
 	log.Println("Playground: starting simulation...")
 
 	// === TEST 1 (example from the puzzle description) ===
 	testBoxes := readBoxesFromFile("./test.txt")
-	testTree := buildConnectionTree(testBoxes)
 
 	// Connect the 10 shortest pairs.
-	testCircuits, _ := simulateConnections(len(testBoxes), testTree, 10)
-	testSizes := sortedCircuitSizes(testCircuits)
+	testSizes := sortedCircuitSizes(connectShortest(testBoxes, 10, SquaredEuclidean))
 	testResult := testSizes[0] * testSizes[1] * testSizes[2]
 	log.Println("TEST 1:",
 		"Multiplying the sizes of the three largest circuits:",
 		testResult, "(expected: 40)")
 
 	// === PART 1 ===
-	boxes := readBoxesFromFile("./input.txt")
-	tree := buildConnectionTree(boxes)
+	boxes := readBoxesFromFile(*inputFile)
 
 	// Connect the 1000 shortest pairs.
-	circuits, _ := simulateConnections(len(boxes), tree, 1000)
-	sizes := sortedCircuitSizes(circuits)
+	sizes := sortedCircuitSizes(connectShortest(boxes, 1000, metric))
 	part1 := sizes[0] * sizes[1] * sizes[2]
 	log.Println("PART 1:",
 		"Multiplying the sizes of the three largest circuits:",
@@ -247,18 +208,47 @@ func main() {
 	// === TEST 2 (example from the puzzle description) ===
 	// Continue connecting until everything forms a single circuit and
 	// look at the last connection used.
-	_, testFinalConnection := simulateConnections(len(testBoxes), testTree, -1)
-	testPart2 := testFinalConnection.boxA.x * testFinalConnection.boxB.x
+	testFinalConnection, _ := finalConnection(testBoxes, SquaredEuclidean)
+	testPart2 := testFinalConnection.boxA[0] * testFinalConnection.boxB[0]
 	log.Println("TEST 2:",
 		"Multiplying the X coords of the last connection:",
 		testPart2, "(expected: 25272)")
 
 	// === PART 2 ===
-	_, finalConnection := simulateConnections(len(boxes), tree, -1)
-	part2 := finalConnection.boxA.x * finalConnection.boxB.x
+	lastConnection, _ := finalConnection(boxes, metric)
+	part2 := lastConnection.boxA[0] * lastConnection.boxB[0]
 	log.Println("PART 2:",
 		"Multiplying the X coords of the last connection:",
 		part2)
 	log.Printf("Execution time: %s", time.Since(start))
 
+	if *jsonFile != "" || *dotFile != "" {
+		var w *Wiring
+		if *untilConnected {
+			w = wireUntilConnected(boxes, metric)
+		} else {
+			w = wireShortest(boxes, 1000, metric)
+		}
+		writeExport(*jsonFile, w.WriteJSON)
+		writeExport(*dotFile, w.WriteDOT)
+	}
+}
+
+// writeExport creates filename and fills it with write. An empty filename
+// skips the export.
+func writeExport(filename string, write func(io.Writer) error) {
+	if filename == "" {
+		return
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		log.Fatalf("failed to create %s: %v", filename, err)
+	}
+	if err := write(f); err != nil {
+		log.Fatalf("failed to write %s: %v", filename, err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatalf("failed to write %s: %v", filename, err)
+	}
+	log.Println("Wrote", filename)
 }
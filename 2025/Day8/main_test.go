@@ -0,0 +1,550 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand/v2"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/btree"
+)
+
+func TestDisjointSet(t *testing.T) {
+	d := NewDisjointSet(6)
+	if got := d.Count(); got != 6 {
+		t.Fatalf("Count() = %d; want 6", got)
+	}
+
+	steps := []struct {
+		a, b   int
+		merged bool
+		count  int
+		sizes  []int
+	}{
+		{a: 0, b: 1, merged: true, count: 5, sizes: []int{2, 1, 1, 1, 1}},
+		{a: 2, b: 3, merged: true, count: 4, sizes: []int{2, 2, 1, 1}},
+		{a: 1, b: 0, merged: false, count: 4, sizes: []int{2, 2, 1, 1}},
+		{a: 3, b: 0, merged: true, count: 3, sizes: []int{4, 1, 1}},
+		{a: 5, b: 2, merged: true, count: 2, sizes: []int{5, 1}},
+		{a: 4, b: 1, merged: true, count: 1, sizes: []int{6}},
+	}
+
+	for _, s := range steps {
+		if got := d.Union(s.a, s.b); got != s.merged {
+			t.Errorf("Union(%d, %d) = %v; want %v", s.a, s.b, got, s.merged)
+		}
+		if got := d.Count(); got != s.count {
+			t.Errorf("after Union(%d, %d): Count() = %d; want %d", s.a, s.b, got, s.count)
+		}
+		if got := d.Sizes(); !reflect.DeepEqual(got, s.sizes) {
+			t.Errorf("after Union(%d, %d): Sizes() = %v; want %v", s.a, s.b, got, s.sizes)
+		}
+		if !d.Connected(s.a, s.b) {
+			t.Errorf("after Union(%d, %d): Connected() = false", s.a, s.b)
+		}
+	}
+	if got := d.Size(3); got != 6 {
+		t.Errorf("Size(3) = %d; want 6", got)
+	}
+}
+
+func TestDisjointSetLongChain(t *testing.T) {
+	const n = 100000
+	d := NewDisjointSet(n)
+	for i := 1; i < n; i++ {
+		d.Union(i-1, i)
+	}
+	if got := d.Count(); got != 1 {
+		t.Errorf("Count() = %d; want 1", got)
+	}
+	if got := d.Size(0); got != n {
+		t.Errorf("Size(0) = %d; want %d", got, n)
+	}
+}
+
+func TestExample(t *testing.T) {
+	boxes := readBoxesFromFile("./test.txt")
+	tree := buildConnectionTree(boxes, SquaredEuclidean)
+
+	circuits, _ := simulateConnections(len(boxes), tree, 10)
+	sizes := sortedCircuitSizes(circuits)
+	if got := sizes[0] * sizes[1] * sizes[2]; got != 40 {
+		t.Errorf("part 1 = %d; want 40 (sizes %v)", got, sizes)
+	}
+	if got := circuits.Count(); got != 11 {
+		t.Errorf("circuits after 10 connections = %d; want 11", got)
+	}
+
+	circuits, last := simulateConnections(len(boxes), tree, -1)
+	if got := circuits.Count(); got != 1 {
+		t.Errorf("circuits at the end = %d; want 1", got)
+	}
+	if got := last.boxA[0] * last.boxB[0]; got != 25272 {
+		t.Errorf("part 2 = %d; want 25272", got)
+	}
+}
+
+// lattice returns the boxes of an n×n×n cube with unit spacing, where
+// almost every distance is shared by many pairs.
+func lattice(n int) []Box {
+	var boxes []Box
+	for x := range n {
+		for y := range n {
+			for z := range n {
+				boxes = append(boxes, Box{x, y, z})
+			}
+		}
+	}
+	return boxes
+}
+
+func TestConnectionTreeKeepsTies(t *testing.T) {
+	boxes := lattice(4)
+	tree := buildConnectionTree(boxes, SquaredEuclidean)
+
+	want := len(boxes) * (len(boxes) - 1) / 2
+	if got := tree.Len(); got != want {
+		t.Fatalf("tree holds %d connections; want %d", got, want)
+	}
+
+	seen := make(map[[2]int]bool)
+	var prev *ConnectionItem
+	tree.Ascend(func(item btree.Item) bool {
+		c := item.(ConnectionItem)
+		if prev != nil && !prev.Less(c) {
+			t.Errorf("connection %v sorted after %v", c, *prev)
+		}
+		seen[[2]int{c.a, c.b}] = true
+		prev = &c
+		return true
+	})
+	for i := range boxes {
+		for j := i + 1; j < len(boxes); j++ {
+			if !seen[[2]int{i, j}] {
+				t.Errorf("connection between box %d and box %d is missing", i, j)
+			}
+		}
+	}
+}
+
+func TestLatticeConnections(t *testing.T) {
+	tests := []struct {
+		name  string
+		boxes []Box
+		// unit is the number of pairs at distance 1, which is exactly
+		// enough to join every box into one circuit.
+		unit int
+	}{
+		{
+			name:  "Line",
+			boxes: []Box{{0, 0, 0}, {1, 0, 0}, {2, 0, 0}, {3, 0, 0}, {4, 0, 0}, {5, 0, 0}},
+			unit:  5,
+		},
+		{
+			name:  "Cube",
+			boxes: lattice(4),
+			unit:  3 * 4 * 4 * 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree := buildConnectionTree(tt.boxes, SquaredEuclidean)
+
+			circuits, _ := simulateConnections(len(tt.boxes), tree, tt.unit)
+			if got := circuits.Count(); got != 1 {
+				t.Errorf("circuits after %d unit connections = %d; want 1", tt.unit, got)
+			}
+
+			circuits, last := simulateConnections(len(tt.boxes), tree, -1)
+			if got := circuits.Count(); got != 1 {
+				t.Errorf("circuits at the end = %d; want 1", got)
+			}
+			if last.dist != 1 {
+				t.Errorf("last connection distance = %v; want 1", last.dist)
+			}
+		})
+	}
+}
+
+func randomBoxes(rng *rand.Rand, n, spread int) []Box {
+	return randomBoxesDim(rng, n, spread, 3)
+}
+
+func randomBoxesDim(rng *rand.Rand, n, spread, dim int) []Box {
+	boxes := make([]Box, n)
+	for i := range boxes {
+		boxes[i] = make(Box, dim)
+		for axis := range dim {
+			boxes[i][axis] = rng.IntN(spread) - spread/2
+		}
+	}
+	return boxes
+}
+
+func TestNearestMatchesReference(t *testing.T) {
+	rng := rand.New(rand.NewPCG(8, 2025))
+	inputs := map[string][]Box{
+		"Example":         readBoxesFromFile("./test.txt"),
+		"Lattice":         lattice(5),
+		"Random":          randomBoxes(rng, 300, 100000),
+		"Dense with ties": randomBoxes(rng, 300, 6),
+		"Two boxes":       {{1, 2, 3}, {4, 5, 6}},
+		"Duplicates":      {{1, 1, 1}, {1, 1, 1}, {2, 2, 2}, {1, 1, 1}},
+	}
+
+	for name, boxes := range inputs {
+		for _, metric := range []Metric{SquaredEuclidean, Manhattan, Chebyshev} {
+			t.Run(name+"/"+metric.String(), func(t *testing.T) {
+				checkAgainstReference(t, boxes, metric)
+			})
+		}
+	}
+}
+
+func TestNearestOtherDimensions(t *testing.T) {
+	rng := rand.New(rand.NewPCG(2, 4))
+	inputs := map[string][]Box{
+		"1D":         randomBoxesDim(rng, 100, 50, 1),
+		"2D":         randomBoxesDim(rng, 200, 1000, 2),
+		"2D lattice": randomBoxesDim(rng, 200, 8, 2),
+		"4D":         randomBoxesDim(rng, 200, 1000, 4),
+		"4D dense":   randomBoxesDim(rng, 200, 4, 4),
+	}
+
+	for name, boxes := range inputs {
+		for _, metric := range []Metric{SquaredEuclidean, Manhattan, Chebyshev} {
+			t.Run(name+"/"+metric.String(), func(t *testing.T) {
+				checkAgainstReference(t, boxes, metric)
+			})
+		}
+	}
+}
+
+// checkAgainstReference compares the k-d tree queries with the full pair
+// enumeration in buildConnectionTree.
+func checkAgainstReference(t *testing.T, boxes []Box, metric Metric) {
+	t.Helper()
+
+	tree := buildConnectionTree(boxes, metric)
+	var reference []Connection
+	tree.Ascend(func(item btree.Item) bool {
+		reference = append(reference, Connection(item.(ConnectionItem)))
+		return true
+	})
+
+	for _, k := range []int{1, 10, len(boxes), len(reference)} {
+		got := shortestConnections(boxes, k, metric)
+		want := reference[:min(k, len(reference))]
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("shortestConnections(%d) differs from the full enumeration", k)
+		}
+	}
+
+	_, want := simulateConnections(len(boxes), tree, -1)
+	got, ok := finalConnection(boxes, metric)
+	if !ok || !reflect.DeepEqual(got, Connection(want)) {
+		t.Errorf("finalConnection() = %v, %v; want %v", got, ok, want)
+	}
+}
+
+func TestNearestScalesToManyBoxes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("large layout")
+	}
+
+	rng := rand.New(rand.NewPCG(100, 000))
+	boxes := randomBoxes(rng, 100000, 1000000)
+
+	conns := shortestConnections(boxes, 1000, SquaredEuclidean)
+	if len(conns) != 1000 {
+		t.Fatalf("shortestConnections() returned %d connections; want 1000", len(conns))
+	}
+	for i := 1; i < len(conns); i++ {
+		if !pairLess(conns[i-1].dist, conns[i-1].a, conns[i-1].b, conns[i].dist, conns[i].a, conns[i].b) {
+			t.Fatalf("connection %d is not after connection %d", i, i-1)
+		}
+	}
+
+	mst := minimumSpanningTree(boxes, SquaredEuclidean)
+	if len(mst) != len(boxes)-1 {
+		t.Errorf("spanning tree has %d edges; want %d", len(mst), len(boxes)-1)
+	}
+	circuits := NewDisjointSet(len(boxes))
+	for _, c := range mst {
+		circuits.Union(c.a, c.b)
+	}
+	if circuits.Count() != 1 {
+		t.Errorf("spanning tree leaves %d circuits; want 1", circuits.Count())
+	}
+}
+
+func TestFinalConnectionTooFewBoxes(t *testing.T) {
+	if _, ok := finalConnection([]Box{{1, 2, 3}}, SquaredEuclidean); ok {
+		t.Errorf("finalConnection() ok = true for a single box")
+	}
+	if got := shortestConnections(nil, 5, SquaredEuclidean); len(got) != 0 {
+		t.Errorf("shortestConnections(nil) = %v; want none", got)
+	}
+}
+
+func TestMetricDist(t *testing.T) {
+	tests := []struct {
+		a, b   Box
+		metric Metric
+		want   int64
+	}{
+		{Box{0, 0, 0}, Box{1, 2, 3}, SquaredEuclidean, 14},
+		{Box{0, 0, 0}, Box{1, -2, 3}, Manhattan, 6},
+		{Box{0, 0, 0}, Box{1, -2, 3}, Chebyshev, 3},
+		{Box{3, 4}, Box{0, 0}, SquaredEuclidean, 25},
+		{Box{3, 4}, Box{0, 0}, Manhattan, 7},
+		{Box{1, 2, 3, 4}, Box{4, 3, 2, 1}, SquaredEuclidean, 20},
+		{Box{1, 2, 3, 4}, Box{4, 3, 2, 1}, Chebyshev, 3},
+		{Box{-5}, Box{5}, Manhattan, 10},
+	}
+
+	for _, tt := range tests {
+		if got := tt.metric.Dist(tt.a, tt.b); got != tt.want {
+			t.Errorf("%v.Dist(%v, %v) = %d; want %d", tt.metric, tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestParseMetric(t *testing.T) {
+	for _, m := range []Metric{SquaredEuclidean, Manhattan, Chebyshev} {
+		got, err := ParseMetric(m.String())
+		if err != nil || got != m {
+			t.Errorf("ParseMetric(%q) = %v, %v; want %v", m.String(), got, err, m)
+		}
+	}
+	if _, err := ParseMetric("euclid"); err == nil {
+		t.Errorf("ParseMetric(%q) = nil error; want error", "euclid")
+	}
+}
+
+func TestParseBoxes(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Box
+	}{
+		{
+			name:  "Three axes",
+			input: "162,817,812\n57,618,57\n",
+			want:  []Box{{162, 817, 812}, {57, 618, 57}},
+		},
+		{
+			name:  "Two axes with negatives, spaces and blank lines",
+			input: "\n1, -2\n\n -3 ,4\n\n",
+			want:  []Box{{1, -2}, {-3, 4}},
+		},
+		{
+			name:  "Four axes",
+			input: "1,2,3,4\n5,6,7,8",
+			want:  []Box{{1, 2, 3, 4}, {5, 6, 7, 8}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseBoxes(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("parseBoxes() error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseBoxes() = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseBoxesErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		line  string
+	}{
+		{name: "Not a number", input: "1,2,3\n4,x,6\n", line: "line 2"},
+		{name: "Mixed dimensions", input: "1,2,3\n\n4,5\n", line: "line 3"},
+		{name: "Trailing comma", input: "1,2,\n", line: "line 1"},
+		{name: "Other separator", input: "1;2;3\n", line: "line 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseBoxes(strings.NewReader(tt.input))
+			if err == nil || !strings.Contains(err.Error(), tt.line) {
+				t.Errorf("parseBoxes() error = %v; want an error naming %s", err, tt.line)
+			}
+		})
+	}
+}
+
+func TestWiringJSON(t *testing.T) {
+	boxes := readBoxesFromFile("./test.txt")
+	var buf bytes.Buffer
+	if err := wireShortest(boxes, 10, SquaredEuclidean).WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc jsonWiring
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if got := len(doc.Connections); got != 10 {
+		t.Fatalf("connections = %d; want 10", got)
+	}
+	// The first connection in the puzzle joins 162,817,812 and 425,690,689.
+	first := doc.Connections[0]
+	if !reflect.DeepEqual(first.A.Coords, []int{162, 817, 812}) || !reflect.DeepEqual(first.B.Coords, []int{425, 690, 689}) {
+		t.Errorf("first connection = %v-%v; want 162,817,812-425,690,689", first.A.Coords, first.B.Coords)
+	}
+	// The fourth one joins two boxes already in the same circuit.
+	if doc.Connections[3].Merged {
+		t.Errorf("connection 4 merged; want it inside an existing circuit")
+	}
+
+	var sizes []int
+	steps := 0
+	for _, c := range doc.Circuits {
+		if c.Size != len(c.Boxes) {
+			t.Errorf("circuit size %d with %d boxes", c.Size, len(c.Boxes))
+		}
+		sizes = append(sizes, c.Size)
+		for i, conn := range c.Connections {
+			if i > 0 && conn.Step <= c.Connections[i-1].Step {
+				t.Errorf("circuit connections out of order: %d after %d", conn.Step, c.Connections[i-1].Step)
+			}
+		}
+		steps += len(c.Connections)
+	}
+	if want := []int{5, 4, 2, 2, 1, 1, 1, 1, 1, 1, 1}; !reflect.DeepEqual(sizes, want) {
+		t.Errorf("circuit sizes = %v; want %v", sizes, want)
+	}
+	if steps != 10 {
+		t.Errorf("connections across circuits = %d; want 10", steps)
+	}
+	if doc.Last == nil || doc.Last.Step != 10 {
+		t.Errorf("last = %+v; want step 10", doc.Last)
+	}
+}
+
+func TestWiringUntilConnected(t *testing.T) {
+	boxes := readBoxesFromFile("./test.txt")
+	w := wireUntilConnected(boxes, SquaredEuclidean)
+
+	if got := len(w.Circuits()); got != 1 {
+		t.Fatalf("circuits = %d; want 1", got)
+	}
+	if got := len(w.steps); got != len(boxes)-1 {
+		t.Errorf("connections = %d; want %d", got, len(boxes)-1)
+	}
+	last := w.steps[len(w.steps)-1]
+	if got := last.boxA[0] * last.boxB[0]; got != 25272 {
+		t.Errorf("last connection X product = %d; want 25272", got)
+	}
+
+	var buf bytes.Buffer
+	if err := w.WriteDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	dot := buf.String()
+	if !strings.HasPrefix(dot, "graph circuits {") || !strings.HasSuffix(dot, "}\n") {
+		t.Errorf("DOT output is not a single graph:\n%s", dot)
+	}
+	if got := strings.Count(dot, " -- "); got != len(boxes)-1 {
+		t.Errorf("DOT edges = %d; want %d", got, len(boxes)-1)
+	}
+	if got := strings.Count(dot, "color=red"); got != 1 {
+		t.Errorf("highlighted edges = %d; want 1", got)
+	}
+	if strings.Contains(dot, "style=dashed") {
+		t.Errorf("spanning forest has a dashed edge")
+	}
+}
+
+func TestSimulatorMatchesReference(t *testing.T) {
+	rng := rand.New(rand.NewPCG(39, 1))
+	inputs := map[string][]Box{
+		"Example":         readBoxesFromFile("./test.txt"),
+		"Dense with ties": randomBoxes(rng, 60, 5),
+	}
+
+	for name, boxes := range inputs {
+		t.Run(name, func(t *testing.T) {
+			tree := buildConnectionTree(boxes, SquaredEuclidean)
+			sim := NewSimulator(boxes, SquaredEuclidean)
+			total := len(boxes) * (len(boxes) - 1) / 2
+
+			for n := 1; n <= total; n++ {
+				got, ok := sim.Step()
+				if !ok {
+					t.Fatalf("Step %d: ran out of connections", n)
+				}
+				circuits, want := simulateConnections(len(boxes), tree, n)
+				if !reflect.DeepEqual(got, Connection(want)) {
+					t.Fatalf("Step %d = %v; want %v", n, got, want)
+				}
+				if sim.ComponentCount() != circuits.Count() {
+					t.Fatalf("after %d: ComponentCount() = %d; want %d", n, sim.ComponentCount(), circuits.Count())
+				}
+				if got, want := sim.TopSizes(3), circuits.Sizes()[:min(3, circuits.Count())]; !reflect.DeepEqual(got, want) {
+					t.Fatalf("after %d: TopSizes(3) = %v; want %v", n, got, want)
+				}
+				a, b := rng.IntN(len(boxes)), rng.IntN(len(boxes))
+				if sim.Connected(a, b) != circuits.Connected(a, b) {
+					t.Fatalf("after %d: Connected(%d, %d) = %t; want %t", n, a, b, sim.Connected(a, b), circuits.Connected(a, b))
+				}
+			}
+
+			if _, ok := sim.Step(); ok {
+				t.Errorf("Step after every pair reported another connection")
+			}
+			if sim.Steps() != total {
+				t.Errorf("Steps() = %d; want %d", sim.Steps(), total)
+			}
+		})
+	}
+}
+
+func TestSimulatorStepUntil(t *testing.T) {
+	boxes := readBoxesFromFile("./test.txt")
+	sim := NewSimulator(boxes, SquaredEuclidean)
+
+	if _, ok := sim.Last(); ok {
+		t.Errorf("Last() before any step reported a connection")
+	}
+
+	taken, ok := sim.StepUntil(func(s *Simulator) bool { return s.Steps() == 10 })
+	if !ok || taken != 10 {
+		t.Fatalf("StepUntil(10 steps) = %d, %t; want 10, true", taken, ok)
+	}
+	if got := sim.TopSizes(3); !reflect.DeepEqual(got, []int{5, 4, 2}) {
+		t.Errorf("TopSizes(3) = %v; want [5 4 2]", got)
+	}
+	if got := sim.TopSizes(100); len(got) != sim.ComponentCount() {
+		t.Errorf("TopSizes(100) has %d sizes; want one per circuit, %d", len(got), sim.ComponentCount())
+	}
+
+	// Continuing from step 10 must reach the same final connection as part 2.
+	_, ok = sim.StepUntil(func(s *Simulator) bool { return s.ComponentCount() == 1 })
+	if !ok {
+		t.Fatal("StepUntil(one circuit) ran out of connections")
+	}
+	last, _ := sim.Last()
+	if got := last.boxA[0] * last.boxB[0]; got != 25272 {
+		t.Errorf("last connection X product = %d; want 25272", got)
+	}
+
+	taken, ok = sim.StepUntil(func(s *Simulator) bool { return s.ComponentCount() == 1 })
+	if !ok || taken != 0 {
+		t.Errorf("StepUntil on a condition that already holds = %d, %t; want 0, true", taken, ok)
+	}
+	taken, ok = sim.StepUntil(func(*Simulator) bool { return false })
+	if ok || sim.Steps() != len(boxes)*(len(boxes)-1)/2 {
+		t.Errorf("StepUntil(never) = %d, %t after %d steps; want false after every pair", taken, ok, sim.Steps())
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// Metric is the distance used to rank connections. Every metric returns an
+// exact integer, so ordering never depends on floating-point rounding.
+type Metric int
+
+const (
+	// SquaredEuclidean ranks pairs like straight-line distance does.
+	SquaredEuclidean Metric = iota
+	// Manhattan is the taxicab distance, the sum of the axis differences.
+	Manhattan
+	// Chebyshev is the largest single axis difference.
+	Chebyshev
+)
+
+func (m Metric) String() string {
+	switch m {
+	case SquaredEuclidean:
+		return "squared-euclidean"
+	case Manhattan:
+		return "manhattan"
+	case Chebyshev:
+		return "chebyshev"
+	}
+	return fmt.Sprintf("Metric(%d)", int(m))
+}
+
+// ParseMetric returns the metric named by String.
+func ParseMetric(name string) (Metric, error) {
+	for _, m := range []Metric{SquaredEuclidean, Manhattan, Chebyshev} {
+		if m.String() == name {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown metric %q", name)
+}
+
+// Dist returns the distance between two boxes of the same dimension.
+// Squared Euclidean distances overflow once the axis differences approach
+// 3e9 divided by the square root of the dimension.
+func (m Metric) Dist(a, b Box) int64 {
+	var d int64
+	switch m {
+	case SquaredEuclidean:
+		for i := range a {
+			diff := int64(a[i] - b[i])
+			d += diff * diff
+		}
+	case Manhattan:
+		for i := range a {
+			d += abs(int64(a[i] - b[i]))
+		}
+	case Chebyshev:
+		for i := range a {
+			d = max(d, abs(int64(a[i]-b[i])))
+		}
+	}
+	return d
+}
+
+func abs(x int64) int64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// axisBound returns the smallest distance two boxes can have when they
+// differ by diff along one axis. The k-d tree uses it to skip subtrees.
+func (m Metric) axisBound(diff int64) int64 {
+	if m == SquaredEuclidean {
+		return diff * diff
+	}
+	return abs(diff)
+}
+
+// Length converts a distance from Dist into the length a person would
+// measure, taking the square root for SquaredEuclidean.
+func (m Metric) Length(d int64) float64 {
+	if m == SquaredEuclidean {
+		return math.Sqrt(float64(d))
+	}
+	return float64(d)
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// This file answers the two puzzle queries without building every pair:
+//
+//   - shortestConnections merges per-box nearest-neighbour streams from a
+//     k-d tree, so only as many neighbours are looked up as the first k
+//     connections need.
+//   - finalConnection builds the minimum spanning tree with Borůvka's
+//     algorithm on the same k-d tree. Connecting pairs shortest first
+//     joins everything exactly when the longest spanning-tree edge is
+//     added, so that edge is the last connection.
+//
+// Both order pairs like ConnectionItem.Less: by distance under the chosen
+// Metric, then by box indices. That order is strict, so the spanning tree is unique and the
+// results match the full pair enumeration exactly.
+
+// pairLess orders the pair (i, j) at distance d before the pair (k, l) at
+// distance e. The pairs do not need to be normalised.
+func pairLess(d int64, i, j int, e int64, k, l int) bool {
+	if d != e {
+		return d < e
+	}
+	i, j = min(i, j), max(i, j)
+	k, l = min(k, l), max(k, l)
+	if i != k {
+		return i < k
+	}
+	return j < l
+}
+
+// kdTree is an implicit k-d tree over box indices: the node for the range
+// [lo, hi) of order is order[(lo+hi)/2], splitting on axis depth%dim, with
+// children [lo, mid) and [mid+1, hi).
+type kdTree struct {
+	boxes  []Box
+	order  []int
+	dim    int
+	metric Metric
+}
+
+func newKDTree(boxes []Box, metric Metric) *kdTree {
+	t := &kdTree{boxes: boxes, order: make([]int, len(boxes)), dim: 1, metric: metric}
+	if len(boxes) > 0 {
+		t.dim = max(1, len(boxes[0]))
+	}
+	for i := range t.order {
+		t.order[i] = i
+	}
+	t.build(0, len(boxes), 0)
+	return t
+}
+
+func (t *kdTree) build(lo, hi, depth int) {
+	if hi-lo <= 1 {
+		return
+	}
+	axis := depth % t.dim
+	part := t.order[lo:hi]
+	sort.Slice(part, func(i, j int) bool {
+		return t.boxes[part[i]][axis] < t.boxes[part[j]][axis]
+	})
+	mid := (lo + hi) / 2
+	t.build(lo, mid, depth+1)
+	t.build(mid+1, hi, depth+1)
+}
+
+// neighbour is a candidate partner j of a query box at distance d.
+type neighbour struct {
+	j int
+	d int64
+}
+
+// neighbourHeap is a max-heap holding the best candidates found so far.
+type neighbourHeap []neighbour
+
+func (h neighbourHeap) Len() int { return len(h) }
+func (h neighbourHeap) Less(a, b int) bool {
+	if h[a].d != h[b].d {
+		return h[a].d > h[b].d
+	}
+	return h[a].j > h[b].j
+}
+func (h neighbourHeap) Swap(a, b int) { h[a], h[b] = h[b], h[a] }
+func (h *neighbourHeap) Push(x any)   { *h = append(*h, x.(neighbour)) }
+func (h *neighbourHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// nearest returns the k boxes closest to box i, excluding i, sorted by
+// distance and then index.
+func (t *kdTree) nearest(i, k int) []neighbour {
+	best := make(neighbourHeap, 0, k)
+	q := t.boxes[i]
+
+	var visit func(lo, hi, depth int)
+	visit = func(lo, hi, depth int) {
+		if lo >= hi {
+			return
+		}
+		mid := (lo + hi) / 2
+		j := t.order[mid]
+		if j != i {
+			n := neighbour{j: j, d: t.metric.Dist(q, t.boxes[j])}
+			if len(best) < k {
+				heap.Push(&best, n)
+			} else if n.d < best[0].d || (n.d == best[0].d && n.j < best[0].j) {
+				best[0] = n
+				heap.Fix(&best, 0)
+			}
+		}
+
+		axis := depth % t.dim
+		diff := int64(q[axis] - t.boxes[j][axis])
+		nearLo, nearHi, farLo, farHi := lo, mid, mid+1, hi
+		if diff > 0 {
+			nearLo, nearHi, farLo, farHi = mid+1, hi, lo, mid
+		}
+		visit(nearLo, nearHi, depth+1)
+		// Equal distances still have to be visited: a tie with a lower
+		// index wins.
+		if len(best) < k || t.metric.axisBound(diff) <= best[0].d {
+			visit(farLo, farHi, depth+1)
+		}
+	}
+	visit(0, len(t.order), 0)
+
+	sort.Slice(best, func(a, b int) bool {
+		if best[a].d != best[b].d {
+			return best[a].d < best[b].d
+		}
+		return best[a].j < best[b].j
+	})
+	return best
+}
+
+// neighbourStream yields box i's neighbours closest first, fetching more
+// from the tree whenever it runs out.
+type neighbourStream struct {
+	i    int
+	list []neighbour
+	pos  int
+}
+
+func (s *neighbourStream) peek(t *kdTree) (neighbour, bool) {
+	if s.pos == len(s.list) {
+		if len(s.list) == len(t.boxes)-1 {
+			return neighbour{}, false
+		}
+		s.list = t.nearest(s.i, min(max(1, 2*len(s.list)), len(t.boxes)-1))
+	}
+	return s.list[s.pos], true
+}
+
+// streamHeap is a min-heap of streams keyed by their next pair.
+type streamHeap struct {
+	streams []*neighbourStream
+	heads   []neighbour
+}
+
+func (h *streamHeap) Len() int { return len(h.streams) }
+func (h *streamHeap) Less(a, b int) bool {
+	x, y := h.heads[a], h.heads[b]
+	return pairLess(x.d, h.streams[a].i, x.j, y.d, h.streams[b].i, y.j)
+}
+func (h *streamHeap) Swap(a, b int) {
+	h.streams[a], h.streams[b] = h.streams[b], h.streams[a]
+	h.heads[a], h.heads[b] = h.heads[b], h.heads[a]
+}
+func (h *streamHeap) Push(x any) {
+	s := x.(*neighbourStream)
+	h.streams = append(h.streams, s)
+	h.heads = append(h.heads, s.list[s.pos])
+}
+func (h *streamHeap) Pop() any {
+	n := len(h.streams) - 1
+	s := h.streams[n]
+	h.streams, h.heads = h.streams[:n], h.heads[:n]
+	return s
+}
+
+// connectionStream yields every pair of boxes as a Connection, shortest
+// first in the ConnectionItem order, looking up neighbours only as needed.
+type connectionStream struct {
+	boxes  []Box
+	metric Metric
+	tree   *kdTree
+	heads  *streamHeap
+}
+
+func newConnectionStream(boxes []Box, metric Metric) *connectionStream {
+	cs := &connectionStream{boxes: boxes, metric: metric, tree: newKDTree(boxes, metric), heads: &streamHeap{}}
+	for i := range boxes {
+		s := &neighbourStream{i: i}
+		if _, ok := s.peek(cs.tree); ok {
+			heap.Push(cs.heads, s)
+		}
+	}
+	return cs
+}
+
+// next returns the next connection, or false once every pair has been
+// returned.
+func (cs *connectionStream) next() (Connection, bool) {
+	h := cs.heads
+	for h.Len() > 0 {
+		s, n := h.streams[0], h.heads[0]
+		s.pos++
+		if next, ok := s.peek(cs.tree); ok {
+			h.heads[0] = next
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+		}
+		// Every pair shows up in both boxes' streams; keep the copy from
+		// the lower index.
+		if s.i < n.j {
+			return newConnection(cs.boxes, s.i, n.j, cs.metric), true
+		}
+	}
+	return Connection{}, false
+}
+
+// shortestConnections returns the k shortest connections in order, the
+// same ones simulateConnections would consider first.
+func shortestConnections(boxes []Box, k int, metric Metric) []Connection {
+	cs := newConnectionStream(boxes, metric)
+	var conns []Connection
+	for len(conns) < k {
+		c, ok := cs.next()
+		if !ok {
+			break
+		}
+		conns = append(conns, c)
+	}
+	return conns
+}
+
+func newConnection(boxes []Box, i, j int, metric Metric) Connection {
+	i, j = min(i, j), max(i, j)
+	return Connection{
+		boxA: boxes[i],
+		boxB: boxes[j],
+		a:    i,
+		b:    j,
+		dist: metric.Dist(boxes[i], boxes[j]),
+	}
+}
+
+// finalConnection returns the connection that joins all boxes into a single
+// circuit when pairs are connected shortest first. It reports false when
+// there are fewer than two boxes.
+func finalConnection(boxes []Box, metric Metric) (Connection, bool) {
+	tree := minimumSpanningTree(boxes, metric)
+	if len(tree) == 0 {
+		return Connection{}, false
+	}
+	last := tree[0]
+	for _, c := range tree[1:] {
+		if pairLess(last.dist, last.a, last.b, c.dist, c.a, c.b) {
+			last = c
+		}
+	}
+	return last, true
+}
+
+// minimumSpanningTree returns the edges of the minimum spanning tree under
+// the pair order, in no particular order.
+func minimumSpanningTree(boxes []Box, metric Metric) []Connection {
+	t := newKDTree(boxes, metric)
+	circuits := NewDisjointSet(len(boxes))
+	comp := make([]int, len(boxes))
+	uniform := make([]int, len(boxes))
+	// lower[i] never exceeds the distance from box i to the
+	// nearest box outside its circuit. Circuits only grow, so a bound
+	// found in one round still holds in the next.
+	lower := make([]int64, len(boxes))
+	order := make([]int, len(boxes))
+	for i := range order {
+		order[i] = i
+	}
+	var tree []Connection
+
+	for circuits.Count() > 1 {
+		for i := range comp {
+			comp[i] = circuits.Find(i)
+		}
+		t.markUniform(comp, uniform, 0, len(boxes))
+
+		// Boxes likely to be near another circuit go first, so each
+		// circuit gets a tight limit early and most other boxes can be
+		// skipped without searching.
+		sort.Slice(order, func(a, b int) bool { return lower[order[a]] < lower[order[b]] })
+
+		// The cheapest edge leaving each circuit, by circuit root.
+		best := make(map[int]neighbour)
+		from := make(map[int]int)
+		for _, i := range order {
+			c := comp[i]
+			// Only candidates at least as close as the circuit's best so
+			// far can improve on it.
+			limit := int64(-1)
+			if b, seen := best[c]; seen {
+				if lower[i] > b.d {
+					continue
+				}
+				limit = b.d
+			}
+			n, ok := t.nearestOther(i, comp, uniform, limit)
+			if !ok {
+				lower[i] = limit + 1
+				continue
+			}
+			lower[i] = n.d
+			if b, seen := best[c]; !seen || pairLess(n.d, i, n.j, b.d, from[c], b.j) {
+				best[c] = n
+				from[c] = i
+			}
+		}
+
+		for c, n := range best {
+			if circuits.Union(from[c], n.j) {
+				tree = append(tree, newConnection(boxes, from[c], n.j, metric))
+			}
+		}
+	}
+	return tree
+}
+
+// markUniform stores in uniform[mid] the circuit shared by every box in the
+// subtree [lo, hi), or -1 when the subtree spans several circuits.
+func (t *kdTree) markUniform(comp, uniform []int, lo, hi int) int {
+	if lo >= hi {
+		return -2
+	}
+	mid := (lo + hi) / 2
+	c := comp[t.order[mid]]
+	for _, sub := range []int{t.markUniform(comp, uniform, lo, mid), t.markUniform(comp, uniform, mid+1, hi)} {
+		if sub != -2 && sub != c {
+			c = -1
+		}
+	}
+	uniform[mid] = c
+	return c
+}
+
+// nearestOther returns the box closest to i that is in another circuit.
+// With a non-negative limit, only boxes at distance up to limit
+// are considered.
+func (t *kdTree) nearestOther(i int, comp, uniform []int, limit int64) (neighbour, bool) {
+	q := t.boxes[i]
+	best := neighbour{j: -1, d: limit}
+
+	var visit func(lo, hi, depth int)
+	visit = func(lo, hi, depth int) {
+		if lo >= hi {
+			return
+		}
+		mid := (lo + hi) / 2
+		if uniform[mid] == comp[i] {
+			return
+		}
+		j := t.order[mid]
+		if comp[j] != comp[i] {
+			d := t.metric.Dist(q, t.boxes[j])
+			if (best.j < 0 && (best.d < 0 || d <= best.d)) || d < best.d || (d == best.d && j < best.j) {
+				best = neighbour{j: j, d: d}
+			}
+		}
+
+		axis := depth % t.dim
+		diff := int64(q[axis] - t.boxes[j][axis])
+		nearLo, nearHi, farLo, farHi := lo, mid, mid+1, hi
+		if diff > 0 {
+			nearLo, nearHi, farLo, farHi = mid+1, hi, lo, mid
+		}
+		visit(nearLo, nearHi, depth+1)
+		if best.d < 0 || t.metric.axisBound(diff) <= best.d {
+			visit(farLo, farHi, depth+1)
+		}
+	}
+	visit(0, len(t.order), 0)
+
+	return best, best.j >= 0
+}
@@ -0,0 +1,102 @@
+package main
+
+// Simulator connects boxes one pair at a time, shortest first, and answers
+// questions about the circuits between steps. Unlike simulateConnections it
+// never starts over, so the state after every number of connections can be
+// inspected in a single pass.
+type Simulator struct {
+	boxes    []Box
+	stream   *connectionStream
+	circuits *DisjointSet
+	steps    int
+	last     Connection
+
+	// circuitsOfSize[s] is the number of circuits with exactly s boxes,
+	// so TopSizes does not have to sort every circuit.
+	circuitsOfSize []int
+	largest        int
+}
+
+// NewSimulator returns a simulator with no connections made yet, every box
+// in a circuit of its own.
+func NewSimulator(boxes []Box, metric Metric) *Simulator {
+	s := &Simulator{
+		boxes:          boxes,
+		stream:         newConnectionStream(boxes, metric),
+		circuits:       NewDisjointSet(len(boxes)),
+		circuitsOfSize: make([]int, len(boxes)+1),
+	}
+	if len(boxes) > 0 {
+		s.circuitsOfSize[1] = len(boxes)
+		s.largest = 1
+	}
+	return s
+}
+
+// Step makes the next shortest connection and returns it. Connecting two
+// boxes already in the same circuit still counts as a step. It reports false
+// once every pair has been connected.
+func (s *Simulator) Step() (Connection, bool) {
+	c, ok := s.stream.next()
+	if !ok {
+		return Connection{}, false
+	}
+	s.steps++
+	s.last = c
+
+	sa, sb := s.circuits.Size(c.a), s.circuits.Size(c.b)
+	if s.circuits.Union(c.a, c.b) {
+		s.circuitsOfSize[sa]--
+		s.circuitsOfSize[sb]--
+		s.circuitsOfSize[sa+sb]++
+		s.largest = max(s.largest, sa+sb)
+	}
+	return c, true
+}
+
+// StepUntil steps until done reports true and returns the number of steps
+// taken. done is checked before every step, so nothing happens when it
+// already holds. It reports false when the connections ran out first.
+func (s *Simulator) StepUntil(done func(*Simulator) bool) (int, bool) {
+	taken := 0
+	for !done(s) {
+		if _, ok := s.Step(); !ok {
+			return taken, false
+		}
+		taken++
+	}
+	return taken, true
+}
+
+// Steps returns how many connections have been made.
+func (s *Simulator) Steps() int {
+	return s.steps
+}
+
+// Last returns the most recent connection, or false before the first step.
+func (s *Simulator) Last() (Connection, bool) {
+	return s.last, s.steps > 0
+}
+
+// Connected reports whether boxes a and b, given by input index, are in the
+// same circuit.
+func (s *Simulator) Connected(a, b int) bool {
+	return s.circuits.Connected(a, b)
+}
+
+// ComponentCount returns the number of circuits, counting lone boxes.
+func (s *Simulator) ComponentCount() int {
+	return s.circuits.Count()
+}
+
+// TopSizes returns the sizes of the k largest circuits, largest first. It
+// returns fewer when there are fewer circuits.
+func (s *Simulator) TopSizes(k int) []int {
+	var sizes []int
+	for size := s.largest; size > 0 && len(sizes) < k; size-- {
+		for n := s.circuitsOfSize[size]; n > 0 && len(sizes) < k; n-- {
+			sizes = append(sizes, size)
+		}
+	}
+	return sizes
+}
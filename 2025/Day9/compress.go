@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// tileGrid answers "are all tiles of this rectangle red or green?" in O(1)
+// for axis-aligned loops.
+//
+// The distinct X and Y coordinates of the loops split the plane into
+// compressed cells: even indices are the coordinate lines themselves and
+// odd indices the open gaps between neighbouring coordinates. No loop edge
+// passes through the inside of a gap, so all tiles of a cell are either
+// inside the loop or all outside, and one representative tile decides the
+// whole cell. A 2D prefix sum then counts the outside cells within any
+// rectangle.
+type tileGrid struct {
+	xs, ys []int64
+	cols   int
+	// outside[r*(cols+1)+c] counts the outside cells in rows [0, r) and
+	// columns [0, c).
+	outside []int32
+}
+
+// newTileGrid rasterises loops onto their compressed grid. Every edge must
+// be horizontal or vertical. A tile is inside when it lies on any loop or
+// an odd number of loops enclose it, so a hole nested in an outer loop cuts
+// out its interior; Layout.validate checks the nesting. A loop of fewer
+// than three tiles encloses no tiles, like pointInPolygon.
+func newTileGrid(loops ...[]Point) (*tileGrid, error) {
+	g := &tileGrid{}
+	for _, poly := range loops {
+		n := len(poly)
+		for i := 0; i < n; i++ {
+			a, b := poly[i], poly[(i+1)%n]
+			if a.X != b.X && a.Y != b.Y {
+				return nil, fmt.Errorf("edge %d-%d from %v to %v is not axis-aligned", i, (i+1)%n, a, b)
+			}
+		}
+		for _, p := range poly {
+			g.xs = append(g.xs, p.X)
+			g.ys = append(g.ys, p.Y)
+		}
+	}
+	g.xs, g.ys = uniqueSorted(g.xs), uniqueSorted(g.ys)
+	g.cols = max(0, 2*len(g.xs)-1)
+	rows := max(0, 2*len(g.ys)-1)
+
+	g.outside = make([]int32, (rows+1)*(g.cols+1))
+	// flip[c] and cover[c] are per-row difference arrays: a crossing edge
+	// left of column c flips insideness from c on, and boundary edges cover
+	// ranges of columns.
+	flip := make([]bool, g.cols+1)
+	cover := make([]int, g.cols+1)
+	for r := 0; r < rows; r++ {
+		y := cellValue(g.ys, r)
+		clear(flip)
+		clear(cover)
+
+		for _, poly := range loops {
+			n := len(poly)
+			for i := 0; i < n && n >= 3; i++ {
+				a, b := poly[i], poly[(i+1)%n]
+				ca, cb := g.col(a.X), g.col(b.X)
+				if ca > cb {
+					ca, cb = cb, ca
+				}
+				lo, hi := min64(a.Y, b.Y), max64(a.Y, b.Y)
+				switch {
+				case a.Y == b.Y && a.Y == y:
+					cover[ca]++
+					cover[cb+1]--
+				case a.X == b.X && lo <= y && y <= hi:
+					cover[ca]++
+					cover[ca+1]--
+					// Half-open, like the ray in pointInPolygon, so a vertex
+					// on this row counts once.
+					if y < hi {
+						flip[ca+1] = !flip[ca+1]
+					}
+				}
+			}
+		}
+
+		inside, covered := false, 0
+		row := g.outside[(r+1)*(g.cols+1):]
+		above := g.outside[r*(g.cols+1):]
+		for c := 0; c < g.cols; c++ {
+			inside = inside != flip[c]
+			covered += cover[c]
+
+			var out int32
+			if !inside && covered == 0 && hasTiles(g.xs, c) && hasTiles(g.ys, r) {
+				out = 1
+			}
+			row[c+1] = row[c] + above[c+1] - above[c] + out
+		}
+	}
+	return g, nil
+}
+
+// contains reports whether every tile of the inclusive rectangle with
+// corners a and b is inside or on the loop.
+func (g *tileGrid) contains(a, b Point) bool {
+	c1, ok1 := cellIndex(g.xs, min64(a.X, b.X))
+	c2, ok2 := cellIndex(g.xs, max64(a.X, b.X))
+	r1, ok3 := cellIndex(g.ys, min64(a.Y, b.Y))
+	r2, ok4 := cellIndex(g.ys, max64(a.Y, b.Y))
+	if !ok1 || !ok2 || !ok3 || !ok4 {
+		return false
+	}
+
+	w := g.cols + 1
+	sum := g.outside[(r2+1)*w+c2+1] - g.outside[r1*w+c2+1] - g.outside[(r2+1)*w+c1] + g.outside[r1*w+c1]
+	return sum == 0
+}
+
+// col returns the compressed column of a loop X coordinate.
+func (g *tileGrid) col(x int64) int {
+	c, _ := cellIndex(g.xs, x)
+	return c
+}
+
+// cellIndex returns the compressed index of v among vals, or false when v
+// lies outside all of them.
+func cellIndex(vals []int64, v int64) (int, bool) {
+	i := sort.Search(len(vals), func(i int) bool { return vals[i] >= v })
+	switch {
+	case i < len(vals) && vals[i] == v:
+		return 2 * i, true
+	case i == 0 || i == len(vals):
+		return 0, false
+	}
+	return 2*i - 1, true
+}
+
+// cellValue returns a representative coordinate of compressed index k.
+func cellValue(vals []int64, k int) int64 {
+	if k%2 == 0 {
+		return vals[k/2]
+	}
+	return vals[k/2] + 1
+}
+
+// hasTiles reports whether compressed index k holds any whole coordinate.
+// A gap between neighbouring coordinates holds none.
+func hasTiles(vals []int64, k int) bool {
+	return k%2 == 0 || vals[k/2+1]-vals[k/2] > 1
+}
+
+func uniqueSorted(vals []int64) []int64 {
+	sort.Slice(vals, func(i, j int) bool { return vals[i] < vals[j] })
+	out := vals[:0]
+	for _, v := range vals {
+		if len(out) == 0 || v != out[len(out)-1] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// All predicates below are exact. Coordinates must lie within ±maxCoord so
+// that every difference of two coordinates fits in an int64; readPoints
+// rejects anything larger. Products of differences can need up to 126 bits
+// and are compared in 128-bit arithmetic instead of being rounded through
+// float64 or wrapping around in int64.
+
+// maxCoord is the largest coordinate magnitude the predicates accept.
+const maxCoord = 1<<62 - 1
+
+// sub64 returns a-b and panics if the result does not fit in an int64.
+func sub64(a, b int64) int64 {
+	d := a - b
+	if (a >= 0) != (b >= 0) && (d >= 0) != (a >= 0) {
+		panic(fmt.Sprintf("%d - %d overflows int64", a, b))
+	}
+	return d
+}
+
+// mul64 returns a*b and reports false if the product does not fit in an
+// int64.
+func mul64(a, b int64) (int64, bool) {
+	neg := (a < 0) != (b < 0)
+	hi, lo := bits.Mul64(uint64(abs64(a)), uint64(abs64(b)))
+	if hi != 0 || lo > 1<<63 || (lo == 1<<63 && !neg) {
+		return 0, false
+	}
+	if neg {
+		return -int64(lo), true
+	}
+	return int64(lo), true
+}
+
+// int128 is a signed 128-bit value stored as sign and magnitude.
+type int128 struct {
+	neg    bool
+	hi, lo uint64
+}
+
+// mul128 returns the exact product a*b. Neither argument may be
+// math.MinInt64.
+func mul128(a, b int64) int128 {
+	hi, lo := bits.Mul64(uint64(abs64(a)), uint64(abs64(b)))
+	return int128{neg: (a < 0) != (b < 0) && (hi|lo) != 0, hi: hi, lo: lo}
+}
+
+// cmp128 returns -1, 0 or 1 as x is less than, equal to or greater than y.
+func cmp128(x, y int128) int {
+	if x.neg != y.neg {
+		if x.neg {
+			return -1
+		}
+		return 1
+	}
+	c := cmpUint64(x.lo, y.lo)
+	if x.hi != y.hi {
+		c = cmpUint64(x.hi, y.hi)
+	}
+	if x.neg {
+		return -c
+	}
+	return c
+}
+
+func cmpUint64(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// orient returns the turn direction of a→b→c:
+// >0: left turn, <0: right turn, 0: collinear
+func orient(a, b, c Point) int {
+	dx1, dy1 := sub64(b.X, a.X), sub64(b.Y, a.Y)
+	dx2, dy2 := sub64(c.X, a.X), sub64(c.Y, a.Y)
+
+	// Differences below 2^31 cannot overflow, which covers puzzle inputs.
+	const small = 1 << 31
+	if abs64(dx1) < small && abs64(dy1) < small && abs64(dx2) < small && abs64(dy2) < small {
+		o := dx1*dy2 - dy1*dx2
+		switch {
+		case o > 0:
+			return 1
+		case o < 0:
+			return -1
+		}
+		return 0
+	}
+	return cmp128(mul128(dx1, dy2), mul128(dy1, dx2))
+}
+
+// check if point p lies on segment ab (inclusive)
+func onSegment(p, a, b Point) bool {
+	if p.X < min64(a.X, b.X) || p.X > max64(a.X, b.X) {
+		return false
+	}
+	if p.Y < min64(a.Y, b.Y) || p.Y > max64(a.Y, b.Y) {
+		return false
+	}
+	return orient(a, b, p) == 0
+}
+
+// onBoundary reports whether p lies on one of the polygon's edges.
+func onBoundary(p Point, poly []Point) bool {
+	n := len(poly)
+	for i := 0; i < n; i++ {
+		if onSegment(p, poly[i], poly[(i+1)%n]) {
+			return true
+		}
+	}
+	return false
+}
+
+// PointInPolygon: even-odd rule, returns true if inside or on boundary.
+func pointInPolygon(p Point, poly []Point) bool {
+	n := len(poly)
+	if n < 3 {
+		return false
+	}
+
+	if onBoundary(p, poly) {
+		return true
+	}
+
+	inside := false
+	for i := 0; i < n; i++ {
+		lo, hi := poly[i], poly[(i+1)%n]
+		if lo.Y > hi.Y {
+			lo, hi = hi, lo
+		}
+
+		// Does the ray from p towards +x cross the edge? The edge counts
+		// when it spans p.Y half-open, [lo.Y, hi.Y), and p lies left of it
+		// seen from lo towards hi. For a vertical edge that is just
+		// p.X < lo.X; orient decides the general case without division.
+		if lo.Y <= p.Y && p.Y < hi.Y && orient(lo, hi, p) > 0 {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
+
+// properSegmentIntersect returns true if segments ab and cd intersect
+// in a "proper" crossing (interior point), not just touching or overlapping.
+func properSegmentIntersect(a, b, c, d Point) bool {
+	o1 := orient(a, b, c)
+	o2 := orient(a, b, d)
+	o3 := orient(c, d, a)
+	o4 := orient(c, d, b)
+
+	// if any collinear, we treat as non-proper (touching/overlap allowed)
+	if o1 == 0 || o2 == 0 || o3 == 0 || o4 == 0 {
+		return false
+	}
+
+	return (o1 > 0) != (o2 > 0) && (o3 > 0) != (o4 > 0)
+}
+
+// rectangleInsidePolygon checks if the entire inclusive rectangle
+// [x1..x2] x [y1..y2] is contained in the polygon (boundary allowed).
+// poly is the loop formed by the red tiles in order.
+func rectangleInsidePolygon(a, b Point, poly []Point) bool {
+	x1 := min64(a.X, b.X)
+	x2 := max64(a.X, b.X)
+	y1 := min64(a.Y, b.Y)
+	y2 := max64(a.Y, b.Y)
+
+	// corners
+	corners := []Point{
+		{x1, y1},
+		{x2, y1},
+		{x2, y2},
+		{x1, y2},
+	}
+
+	// 1) all corners must be inside or on boundary
+	for _, c := range corners {
+		if !pointInPolygon(c, poly) {
+			return false
+		}
+	}
+
+	// 2) no rectangle edge may properly intersect any polygon edge
+	rectEdges := [][2]Point{
+		{{x1, y1}, {x2, y1}},
+		{{x2, y1}, {x2, y2}},
+		{{x2, y2}, {x1, y2}},
+		{{x1, y2}, {x1, y1}},
+	}
+
+	n := len(poly)
+	for _, e := range rectEdges {
+		ra, rb := e[0], e[1]
+		for i := 0; i < n; i++ {
+			j := (i + 1) % n
+			pa, pb := poly[i], poly[j]
+			if properSegmentIntersect(ra, rb, pa, pb) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
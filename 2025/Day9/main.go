@@ -2,9 +2,11 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -33,9 +35,51 @@ func abs64(a int64) int64 {
 	return a
 }
 
+// Layout is the red-tile loops read from an input file.
+type Layout struct {
+	Points []Point // normalised so the smallest X and Y are 0
+	Origin Point   // input coordinates of the normalised 0,0
+	Lines  []int   // input line number of each point, counting from 1
+	Loops  []Loop  // the loops, covering Points in order
+}
+
+// Loop is one closed loop of red tiles, Points[Start:End] of its Layout.
+// An outer loop encloses floor that rectangles may cover; a hole is a
+// courtyard inside an outer loop that they must not. The tiles on a hole's
+// loop are still floor.
+type Loop struct {
+	Start, End int
+	Hole       bool
+}
+
+// loop returns the points of loop k.
+func (l Layout) loop(k int) []Point {
+	return l.Points[l.Loops[k].Start:l.Loops[k].End]
+}
+
+// loopPoints returns the points of every loop, in order.
+func (l Layout) loopPoints() [][]Point {
+	loops := make([][]Point, len(l.Loops))
+	for k := range l.Loops {
+		loops[k] = l.loop(k)
+	}
+	return loops
+}
+
+// Input returns point i in the coordinates written in the input.
+func (l Layout) Input(i int) Point {
+	return Point{X: l.Points[i].X + l.Origin.X, Y: l.Points[i].Y + l.Origin.Y}
+}
+
 // readPoints reads "x,y" per line and normalizes so that minX, minY become 0.
 // Translation doesn't change areas or inside/outside, but keeps numbers small.
-func readPoints(filename string) []Point {
+// The returned Layout remembers the offset and line numbers to map results
+// back to the input.
+//
+// Blank lines separate loops. A loop may start with a line reading "outer"
+// or "hole"; unmarked loops are outer loops, so a file with a single loop
+// needs no marks at all.
+func readPoints(filename string) Layout {
 	f, err := os.Open(filename)
 	if err != nil {
 		log.Fatalf("failed to open %s: %v", filename, err)
@@ -43,19 +87,34 @@ func readPoints(filename string) []Point {
 	defer f.Close()
 
 	sc := bufio.NewScanner(f)
-	var pts []Point
+	var l Layout
 
 	var minX, minY int64
 	first := true
+	lineNo := 0
+	inLoop := false
 
 	for sc.Scan() {
-		line := sc.Text()
+		lineNo++
+		line := strings.TrimSpace(sc.Text())
 		if line == "" {
+			inLoop = false
 			continue
 		}
+		if !inLoop {
+			l.Loops = append(l.Loops, Loop{Start: len(l.Points), End: len(l.Points)})
+			inLoop = true
+			if line == "outer" || line == "hole" {
+				l.Loops[len(l.Loops)-1].Hole = line == "hole"
+				continue
+			}
+		}
 		var x, y int64
 		if _, err := fmt.Sscanf(line, "%d,%d", &x, &y); err != nil {
-			log.Fatalf("bad line %q: %v", line, err)
+			log.Fatalf("line %d: bad line %q: %v", lineNo, line, err)
+		}
+		if abs64(x) > maxCoord || abs64(y) > maxCoord {
+			log.Fatalf("line %d: coordinates must be within ±%d", lineNo, int64(maxCoord))
 		}
 		if first {
 			minX, minY = x, y
@@ -68,172 +127,74 @@ func readPoints(filename string) []Point {
 				minY = y
 			}
 		}
-		pts = append(pts, Point{X: x, Y: y})
+		l.Points = append(l.Points, Point{X: x, Y: y})
+		l.Lines = append(l.Lines, lineNo)
+		l.Loops[len(l.Loops)-1].End = len(l.Points)
 	}
 	if err := sc.Err(); err != nil {
 		log.Fatalf("scan error: %v", err)
 	}
 
 	// normalize
+	pts := l.Points
+	for i := range pts {
+		if _, ok := mul64(pts[i].X-minX+1, pts[i].Y-minY+1); !ok {
+			log.Fatalf("line %d: tile %d,%d is too far from %d,%d: rectangle areas overflow int64", l.Lines[i], pts[i].X, pts[i].Y, minX, minY)
+		}
+	}
 	for i := range pts {
 		pts[i].X -= minX
 		pts[i].Y -= minY
 	}
+	l.Origin = Point{X: minX, Y: minY}
 
-	return pts
+	return l
 }
 
 // rectangleArea: inclusive grid-rectangle area between two opposite corners.
+// It panics if the area does not fit in an int64; readPoints rejects inputs
+// whose bounding box is that large.
 func rectangleArea(a, b Point) int64 {
-	w := abs64(a.X-b.X) + 1
-	h := abs64(a.Y-b.Y) + 1
-	return w * h
-}
-
-// orientation / cross product: sign tells CW/CCW/collinear.
-// >0: left turn, <0: right turn, 0: collinear
-func orient(a, b, c Point) int64 {
-	return (b.X-a.X)*(c.Y-a.Y) - (b.Y-a.Y)*(c.X-a.X)
-}
-
-// check if point p lies on segment ab (inclusive)
-func onSegment(p, a, b Point) bool {
-	if orient(a, b, p) != 0 {
-		return false
-	}
-	if p.X < min64(a.X, b.X) || p.X > max64(a.X, b.X) {
-		return false
-	}
-	if p.Y < min64(a.Y, b.Y) || p.Y > max64(a.Y, b.Y) {
-		return false
+	w := abs64(sub64(a.X, b.X)) + 1
+	h := abs64(sub64(a.Y, b.Y)) + 1
+	area, ok := mul64(w, h)
+	if !ok {
+		panic(fmt.Sprintf("area of %v-%v overflows int64", a, b))
 	}
-	return true
+	return area
 }
 
-// PointInPolygon: even-odd rule, returns true if inside or on boundary.
-func pointInPolygon(p Point, poly []Point) bool {
-	n := len(poly)
-	if n < 3 {
-		return false
-	}
-
-	// check boundary first
-	for i := 0; i < n; i++ {
-		j := (i + 1) % n
-		if onSegment(p, poly[i], poly[j]) {
-			return true
-		}
-	}
-
-	inside := false
-	px, py := p.X, p.Y
-	for i := 0; i < n; i++ {
-		j := (i + 1) % n
-		xi, yi := poly[i].X, poly[i].Y
-		xj, yj := poly[j].X, poly[j].Y
-
-		// does horizontal ray at y=py intersect edge (yi,yj)?
-		intersects := (yi > py) != (yj > py)
-		if intersects {
-			// compute x coordinate of intersection as float
-			// cast to float64 only here; coordinates are small enough.
-			xIntersect := float64(xi) + float64(py-yi)*float64(xj-xi)/float64(yj-yi)
-			if float64(px) <= xIntersect {
-				inside = !inside
-			}
-		}
-	}
-
-	return inside
-}
-
-// properSegmentIntersect returns true if segments ab and cd intersect
-// in a "proper" crossing (interior point), not just touching or overlapping.
-func properSegmentIntersect(a, b, c, d Point) bool {
-	o1 := orient(a, b, c)
-	o2 := orient(a, b, d)
-	o3 := orient(c, d, a)
-	o4 := orient(c, d, b)
-
-	// if any collinear, we treat as non-proper (touching/overlap allowed)
-	if o1 == 0 || o2 == 0 || o3 == 0 || o4 == 0 {
-		return false
-	}
-
-	return (o1 > 0) != (o2 > 0) && (o3 > 0) != (o4 > 0)
+// Rectangle is the best rectangle of one part: the red tiles at two of its
+// opposite corners, as indices into the loop with I < J, and its area. A
+// zero Area means no rectangle qualified.
+type Rectangle struct {
+	I, J int
+	Area int64
 }
 
-// rectangleInsidePolygon checks if the entire inclusive rectangle
-// [x1..x2] x [y1..y2] is contained in the polygon (boundary allowed).
-// poly is the loop formed by the red tiles in order.
-func rectangleInsidePolygon(a, b Point, poly []Point) bool {
-	x1 := min64(a.X, b.X)
-	x2 := max64(a.X, b.X)
-	y1 := min64(a.Y, b.Y)
-	y2 := max64(a.Y, b.Y)
-
-	// corners
-	corners := []Point{
-		{x1, y1},
-		{x2, y1},
-		{x2, y2},
-		{x1, y2},
-	}
-
-	// 1) all corners must be inside or on boundary
-	for _, c := range corners {
-		if !pointInPolygon(c, poly) {
-			return false
-		}
-	}
-
-	// 2) no rectangle edge may properly intersect any polygon edge
-	rectEdges := [][2]Point{
-		{{x1, y1}, {x2, y1}},
-		{{x2, y1}, {x2, y2}},
-		{{x2, y2}, {x1, y2}},
-		{{x1, y2}, {x1, y1}},
-	}
-
-	n := len(poly)
-	for _, e := range rectEdges {
-		ra, rb := e[0], e[1]
-		for i := 0; i < n; i++ {
-			j := (i + 1) % n
-			pa, pb := poly[i], poly[j]
-			if properSegmentIntersect(ra, rb, pa, pb) {
-				return false
-			}
-		}
-	}
-
-	return true
-}
-
-func solve(filename string) {
-	points := readPoints(filename)
+// largestRectangle returns the largest rectangle from any two red tiles
+// (no restriction). Ties go to the pair that comes first in input order.
+func largestRectangle(points []Point) Rectangle {
+	var best Rectangle
 	n := len(points)
-	if n < 2 {
-		log.Fatalf("need at least 2 red tiles")
-	}
-
-	// Part 1: largest rectangle from any two red tiles (no restriction)
-	var maxArea1 int64 = 0
 	for i := 0; i < n; i++ {
 		for j := i + 1; j < n; j++ {
 			a := points[i]
 			b := points[j]
 			area := rectangleArea(a, b)
-			if area > maxArea1 {
-				maxArea1 = area
+			if area > best.Area {
+				best = Rectangle{I: i, J: j, Area: area}
 			}
 		}
 	}
+	return best
+}
 
-	// Part 2: same, but rectangle must lie entirely in polygon whose
-	// vertices are the red tiles in input order.
-	// points already represent the loop (wrap-around).
-	var maxArea2 int64 = 0
+// largestContainedRectangle is largestRectangle restricted to rectangles
+// that lie entirely in the floor grid was built from.
+func largestContainedRectangle(points []Point, grid *tileGrid) Rectangle {
+	var best Rectangle
+	n := len(points)
 	for i := 0; i < n; i++ {
 		for j := i + 1; j < n; j++ {
 			a := points[i]
@@ -241,18 +202,81 @@ func solve(filename string) {
 
 			area := rectangleArea(a, b)
 			// quick pruning: cannot beat current best
-			if area <= maxArea2 {
+			if area <= best.Area {
 				continue
 			}
 
-			if rectangleInsidePolygon(a, b, points) {
-				maxArea2 = area
+			if grid.contains(a, b) {
+				best = Rectangle{I: i, J: j, Area: area}
 			}
 		}
 	}
+	return best
+}
 
-	fmt.Printf("Part 1: %d\n", maxArea1)
-	fmt.Printf("Part 2: %d\n", maxArea2)
+// describe formats r with its corners in input coordinates, for example
+// "50 (2,5 on line 6 to 11,1 on line 2)".
+func (l Layout) describe(r Rectangle) string {
+	if r.Area == 0 {
+		return "0 (no rectangle fits)"
+	}
+	a, b := l.Input(r.I), l.Input(r.J)
+	return fmt.Sprintf("%d (%d,%d on line %d to %d,%d on line %d)",
+		r.Area, a.X, a.Y, l.Lines[r.I], b.X, b.Y, l.Lines[r.J])
+}
+
+// SolveOptions change how solve treats the input.
+type SolveOptions struct {
+	// Repair removes duplicate and collinear tiles from the loop before
+	// validating it.
+	Repair bool
+}
+
+// solve returns the best rectangle of both parts for the red tiles in
+// filename. The tiles must form a simple axis-aligned loop; otherwise every
+// violation is logged and solve exits.
+func solve(filename string, opts SolveOptions) (Layout, Rectangle, Rectangle) {
+	layout := readPoints(filename)
+	if len(layout.Points) < 2 {
+		log.Fatalf("need at least 2 red tiles")
+	}
+	if opts.Repair {
+		if removed := layout.Repair(); removed > 0 {
+			log.Printf("removed %d duplicate or collinear red tiles", removed)
+		}
+	}
+	if violations := layout.validate(); len(violations) > 0 {
+		repairable := false
+		for _, v := range violations {
+			log.Println(layout.explain(v))
+			repairable = repairable || (v.Kind.repairable() && !opts.Repair)
+		}
+		hint := ""
+		if repairable {
+			hint = " (-repair removes duplicate and collinear tiles)"
+		}
+		noun := "problems"
+		if len(violations) == 1 {
+			noun = "problem"
+		}
+		log.Fatalf("red tiles do not form simple axis-aligned loops: %d %s%s", len(violations), noun, hint)
+	}
+	points := layout.Points
+
+	// Part 1: largest rectangle from any two red tiles (no restriction)
+	best1 := largestRectangle(points)
+
+	// Part 2: same, but rectangle must lie entirely in the floor enclosed
+	// by the outer loops and outside the holes. Each loop's vertices are
+	// its red tiles in input order, wrapping around. The corners may come
+	// from different loops.
+	grid, err := newTileGrid(layout.loopPoints()...)
+	if err != nil {
+		log.Fatalf("red tiles do not form axis-aligned loops: %v", err)
+	}
+	best2 := largestContainedRectangle(points, grid)
+
+	return layout, best1, best2
 }
 
 func main() {
@@ -261,11 +285,29 @@ func main() {
 	// measure execution time
 	start := time.Now()
 
-	if len(os.Args) < 2 {
-		// default to input.txt if no arg
-		solve("input.txt")
-	} else {
-		solve(os.Args[1])
+	repair := flag.Bool("repair", false, "remove duplicate and collinear red tiles instead of rejecting them")
+	svgFile := flag.String("svg", "", "also plot the loop and both best rectangles as SVG to this file")
+	flag.Parse()
+
+	filename := "input.txt" // default to input.txt if no arg
+	if flag.NArg() >= 1 {
+		filename = flag.Arg(0)
+	}
+	layout, best1, best2 := solve(filename, SolveOptions{Repair: *repair})
+	fmt.Printf("Part 1: %s\n", layout.describe(best1))
+	fmt.Printf("Part 2: %s\n", layout.describe(best2))
+
+	if *svgFile != "" {
+		f, err := os.Create(*svgFile)
+		if err != nil {
+			log.Fatalf("failed to create %s: %v", *svgFile, err)
+		}
+		if err := layout.WriteSVG(f, best1, best2); err != nil {
+			log.Fatalf("failed to write %s: %v", *svgFile, err)
+		}
+		if err := f.Close(); err != nil {
+			log.Fatalf("failed to write %s: %v", *svgFile, err)
+		}
 	}
 
 	elapsed := time.Since(start)
@@ -1,8 +1,15 @@
 package main
 
 import (
+	"encoding/xml"
+	"io"
 	"math"
+	"math/big"
 	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -241,25 +248,34 @@ func TestExampleFromAoC(t *testing.T) {
 	// After translation we get the same shape.
 	// Now test the known valid and invalid rectangles from description.
 
-	// A valid rectangle area=24 between (2,5) & (9,7)
-	a := Point{2 - minX, 5 - minY}
-	b := Point{9 - minX, 7 - minY}
+	// The valid rectangle with area 24 between (9,5) & (2,3), the
+	// part 2 answer.
+	a := Point{9 - minX, 5 - minY}
+	b := Point{2 - minX, 3 - minY}
 	if !rectangleInsidePolygon(a, b, poly) {
 		t.Fatalf("expected example rectangle (area24) to be valid")
 	}
 
-	// A valid rectangle area=35 between (7,1) & (11,7)
-	a = Point{7 - minX, 1 - minY}
-	b = Point{11 - minX, 7 - minY}
+	// The valid rectangle with area 15 between (7,3) & (11,1)
+	a = Point{7 - minX, 3 - minY}
+	b = Point{11 - minX, 1 - minY}
+	if !rectangleInsidePolygon(a, b, poly) {
+		t.Fatalf("expected example rectangle (area15) to be valid")
+	}
+
+	// The thin valid rectangle with area 3 between (9,7) & (9,5)
+	a = Point{9 - minX, 7 - minY}
+	b = Point{9 - minX, 5 - minY}
 	if !rectangleInsidePolygon(a, b, poly) {
-		t.Fatalf("expected example rectangle (area35) to be valid")
+		t.Fatalf("expected example rectangle (area3) to be valid")
 	}
 
-	// A valid rectangle area=50 between (2,5) & (11,1)
+	// The part 1 rectangle with area 50 between (2,5) & (11,1) leaves
+	// the loop near (2,1).
 	a = Point{2 - minX, 5 - minY}
 	b = Point{11 - minX, 1 - minY}
-	if !rectangleInsidePolygon(a, b, poly) {
-		t.Fatalf("expected example rectangle (area50) to be valid")
+	if rectangleInsidePolygon(a, b, poly) {
+		t.Fatalf("expected example rectangle (area50) to be invalid")
 	}
 
 	// A rectangle that goes outside the loop (should be invalid)
@@ -280,6 +296,10 @@ func TestFuzzRectangleInsidePolygon(t *testing.T) {
 		{50, 50},
 		{0, 50},
 	}
+	grid, err := newTileGrid(poly)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	for it := 0; it < 5000; it++ {
 		x1 := rand.Int63n(60) - 5 // allow some outside
@@ -302,5 +322,548 @@ func TestFuzzRectangleInsidePolygon(t *testing.T) {
 				t.Fatalf("rectangleInsidePolygon accepted outside rectangle %v,%v", a, b)
 			}
 		}
+
+		// The compressed grid must agree with the edge-by-edge check.
+		if got, want := grid.contains(a, b), rectangleInsidePolygon(a, b, poly); got != want {
+			t.Fatalf("grid.contains(%v,%v) = %v, rectangleInsidePolygon says %v", a, b, got, want)
+		}
+	}
+}
+
+// staircase returns a random axis-aligned loop whose top and bottom are
+// staircases over the same columns, so it has notches on both sides.
+// With transpose set, X and Y are swapped to get notches left and right.
+func staircase(rng *rand.Rand, steps int, transpose bool) []Point {
+	breaks := []int64{0}
+	for i := 0; i < steps; i++ {
+		breaks = append(breaks, breaks[i]+1+rng.Int63n(3))
+	}
+	height := func(prev int64) int64 {
+		for {
+			if h := rng.Int63n(6); h != prev {
+				return h
+			}
+		}
+	}
+
+	var bottom, top []Point
+	lo, hi := int64(-1), int64(-1)
+	for j := 0; j < steps; j++ {
+		lo, hi = -1-height(-1-lo), height(hi)
+		bottom = append(bottom, Point{breaks[j], lo}, Point{breaks[j+1], lo})
+		top = append(top, Point{breaks[j], hi}, Point{breaks[j+1], hi})
+	}
+
+	poly := bottom
+	for i := len(top) - 1; i >= 0; i-- {
+		poly = append(poly, top[i])
+	}
+	if transpose {
+		for i := range poly {
+			poly[i].X, poly[i].Y = poly[i].Y, poly[i].X
+		}
+	}
+	return poly
+}
+
+func TestTileGridMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(41))
+	for it := 0; it < 200; it++ {
+		poly := staircase(rng, 1+rng.Intn(5), it%2 == 1)
+		grid, err := newTileGrid(poly)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for q := 0; q < 200; q++ {
+			a := Point{rng.Int63n(20) - 8, rng.Int63n(20) - 8}
+			b := Point{rng.Int63n(20) - 8, rng.Int63n(20) - 8}
+
+			want := true
+			for x := min64(a.X, b.X); x <= max64(a.X, b.X) && want; x++ {
+				for y := min64(a.Y, b.Y); y <= max64(a.Y, b.Y) && want; y++ {
+					want = pointInPolygon(Point{x, y}, poly)
+				}
+			}
+			if got := grid.contains(a, b); got != want {
+				t.Fatalf("loop %v: contains(%v,%v) = %v, want %v", poly, a, b, got, want)
+			}
+		}
+	}
+}
+
+func TestTileGridRejectsDiagonalEdges(t *testing.T) {
+	if _, err := newTileGrid([]Point{{0, 0}, {4, 0}, {0, 4}}); err == nil {
+		t.Errorf("newTileGrid accepted a triangle")
+	}
+}
+
+// Coordinates near 2^40 make the cross products in orient reach 2^82, far
+// beyond int64 and float64 precision.
+const big40 = int64(1) << 40
+
+func TestOrientLargeCoordinates(t *testing.T) {
+	rng := rand.New(rand.NewSource(40))
+	bigOrient := func(a, b, c Point) int {
+		l := new(big.Int).Mul(big.NewInt(b.X-a.X), big.NewInt(c.Y-a.Y))
+		r := new(big.Int).Mul(big.NewInt(b.Y-a.Y), big.NewInt(c.X-a.X))
+		return l.Cmp(r)
+	}
+	near := func(base int64) Point {
+		return Point{base + rng.Int63n(1<<20) - 1<<19, -base + rng.Int63n(1<<20) - 1<<19}
+	}
+
+	tests := []struct {
+		a, b, c Point
+		want    int
+	}{
+		// Collinear, but both products are 2^81 and wrap around in int64.
+		{Point{-big40, -big40}, Point{big40, big40}, Point{3 * big40, 3 * big40}, 0},
+		// One unit off the diagonal.
+		{Point{-big40, -big40}, Point{big40, big40}, Point{3 * big40, 3*big40 + 1}, 1},
+		{Point{-big40, -big40}, Point{big40, big40}, Point{3*big40 + 1, 3 * big40}, -1},
+		// The largest accepted coordinates.
+		{Point{-maxCoord, -maxCoord}, Point{maxCoord, -maxCoord}, Point{maxCoord, maxCoord}, 1},
+		{Point{-maxCoord, maxCoord}, Point{maxCoord, -maxCoord}, Point{0, 0}, 0},
+	}
+	for _, tt := range tests {
+		if got := orient(tt.a, tt.b, tt.c); got != tt.want {
+			t.Errorf("orient(%v,%v,%v) = %d, want %d", tt.a, tt.b, tt.c, got, tt.want)
+		}
+	}
+
+	for it := 0; it < 10000; it++ {
+		a, b, c := near(big40), near(-big40), near(big40/2)
+		if it%2 == 0 {
+			// Make c collinear with a and b.
+			c = Point{2*b.X - a.X, 2*b.Y - a.Y}
+		}
+		if got, want := orient(a, b, c), bigOrient(a, b, c); got != want {
+			t.Fatalf("orient(%v,%v,%v) = %d, want %d", a, b, c, got, want)
+		}
+	}
+}
+
+func TestPredicatesLargeCoordinates(t *testing.T) {
+	// The L-shape from TestPointInPolygon_Concave, scaled by 2^20 and moved
+	// to around (2^40, -2^40). Every answer must match the small shape.
+	small := []Point{{0, 0}, {4, 0}, {4, 1}, {1, 1}, {1, 4}, {0, 4}}
+	const scale = int64(1) << 20
+	move := func(p Point) Point {
+		return Point{big40 + p.X*scale, -big40 + p.Y*scale}
+	}
+	poly := make([]Point, len(small))
+	for i, p := range small {
+		poly[i] = move(p)
+	}
+
+	for x := int64(-1); x <= 5; x++ {
+		for y := int64(-1); y <= 5; y++ {
+			p := Point{x, y}
+			if got, want := pointInPolygon(move(p), poly), pointInPolygon(p, small); got != want {
+				t.Errorf("pointInPolygon(%v) = %v, want %v", move(p), got, want)
+			}
+			if got, want := onBoundary(move(p), poly), onBoundary(p, small); got != want {
+				t.Errorf("onBoundary(%v) = %v, want %v", move(p), got, want)
+			}
+			q := Point{4 - x, 4 - y}
+			if got, want := rectangleInsidePolygon(move(p), move(q), poly), rectangleInsidePolygon(p, q, small); got != want {
+				t.Errorf("rectangleInsidePolygon(%v,%v) = %v, want %v", move(p), move(q), got, want)
+			}
+		}
+	}
+
+	// One tile beside an edge, well within float64 rounding of 2^40 * 2^20.
+	edge := move(Point{1, 2})
+	if pointInPolygon(Point{edge.X + 1, edge.Y}, poly) {
+		t.Errorf("pointInPolygon one tile right of the inner edge = true, want false")
+	}
+	if !pointInPolygon(Point{edge.X - 1, edge.Y}, poly) {
+		t.Errorf("pointInPolygon one tile left of the inner edge = false, want true")
+	}
+}
+
+func TestAreaOverflow(t *testing.T) {
+	if got := rectangleArea(Point{big40, big40}, Point{big40 + 1<<20 - 1, big40 - 1<<20 + 1}); got != 1<<40 {
+		t.Errorf("rectangleArea near 2^40 = %d, want %d", got, int64(1)<<40)
+	}
+
+	tests := []struct {
+		a, b int64
+		ok   bool
+	}{
+		{1 << 31, 1 << 31, true},
+		{1 << 32, 1 << 31, false},
+		{-1 << 31, 1 << 32, true},
+		{big40, big40, false},
+		{math.MaxInt64, 1, true},
+		{math.MaxInt64, -1, true},
+	}
+	for _, tt := range tests {
+		if _, ok := mul64(tt.a, tt.b); ok != tt.ok {
+			t.Errorf("mul64(%d, %d) ok = %v, want %v", tt.a, tt.b, ok, tt.ok)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("rectangleArea across 2^40 x 2^40 did not panic")
+		}
+	}()
+	rectangleArea(Point{0, 0}, Point{big40, big40})
+}
+
+func TestSolveReportsInputCorners(t *testing.T) {
+	// The example loop moved to negative X, marked as an outer loop so line
+	// numbers and point indices differ.
+	input := "outer\n-993,1001\n-989,1001\n-989,1007\n-991,1007\n-991,1005\n-998,1005\n-998,1003\n-993,1003\n"
+	filename := filepath.Join(t.TempDir(), "tiles.txt")
+	if err := os.WriteFile(filename, []byte(input), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	layout, best1, best2 := solve(filename, SolveOptions{})
+	if layout.Origin != (Point{-998, 1001}) {
+		t.Errorf("Origin = %v, want {-998 1001}", layout.Origin)
+	}
+
+	tests := []struct {
+		name         string
+		got          Rectangle
+		area         int64
+		a, b         Point
+		lineA, lineB int
+	}{
+		{"part 1", best1, 50, Point{-989, 1001}, Point{-998, 1005}, 3, 7},
+		{"part 2", best2, 24, Point{-991, 1005}, Point{-998, 1003}, 6, 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.got
+			if r.Area != tt.area {
+				t.Errorf("Area = %d, want %d", r.Area, tt.area)
+			}
+			if a, b := layout.Input(r.I), layout.Input(r.J); a != tt.a || b != tt.b {
+				t.Errorf("corners = %v, %v, want %v, %v", a, b, tt.a, tt.b)
+			}
+			if la, lb := layout.Lines[r.I], layout.Lines[r.J]; la != tt.lineA || lb != tt.lineB {
+				t.Errorf("lines = %d, %d, want %d, %d", la, lb, tt.lineA, tt.lineB)
+			}
+		})
+	}
+
+	want := "24 (-991,1005 on line 6 to -998,1003 on line 8)"
+	if got := layout.describe(best2); got != want {
+		t.Errorf("describe = %q, want %q", got, want)
+	}
+}
+
+func TestValidateLoop(t *testing.T) {
+	tests := []struct {
+		name string
+		poly []Point
+		want []Violation
+	}{
+		{
+			name: "example",
+			poly: []Point{{7, 1}, {11, 1}, {11, 7}, {9, 7}, {9, 5}, {2, 5}, {2, 3}, {7, 3}},
+		},
+		{
+			name: "too few",
+			poly: []Point{{0, 0}, {4, 0}, {4, 4}},
+			want: []Violation{{TooFewVertices, []int{0, 1, 2}}},
+		},
+		{
+			name: "duplicate",
+			poly: []Point{{0, 0}, {4, 0}, {4, 0}, {4, 4}, {0, 4}},
+			want: []Violation{{DuplicateVertex, []int{1, 2}}},
+		},
+		{
+			name: "duplicate across the wrap",
+			poly: []Point{{0, 0}, {4, 0}, {4, 4}, {0, 4}, {0, 0}},
+			want: []Violation{{DuplicateVertex, []int{4, 0}}},
+		},
+		{
+			name: "collinear",
+			poly: []Point{{0, 0}, {2, 0}, {4, 0}, {4, 4}, {0, 4}},
+			want: []Violation{{CollinearVertex, []int{0, 1, 2}}},
+		},
+		{
+			name: "diagonal",
+			poly: []Point{{0, 0}, {4, 0}, {4, 4}, {2, 6}, {0, 4}},
+			want: []Violation{{DiagonalEdge, []int{2, 3}}, {DiagonalEdge, []int{3, 4}}},
+		},
+		{
+			name: "crossing",
+			poly: []Point{{0, 0}, {6, 0}, {6, 4}, {3, 4}, {3, -2}, {0, -2}},
+			want: []Violation{{SelfIntersection, []int{0, 1, 3, 4}}},
+		},
+		{
+			name: "doubling back",
+			poly: []Point{{0, 0}, {4, 0}, {4, 4}, {4, 2}, {0, 2}},
+			want: []Violation{
+				{SelfIntersection, []int{1, 2, 3}},
+				{SelfIntersection, []int{1, 2, 3, 4}},
+			},
+		},
+		{
+			name: "figure eight through a repeated point",
+			poly: []Point{{0, 0}, {2, 0}, {2, 2}, {4, 2}, {4, 4}, {2, 4}, {2, 2}, {0, 2}},
+			want: []Violation{{RepeatedPoint, []int{2, 6}}},
+		},
+		{
+			name: "notch down onto an edge",
+			poly: []Point{{0, 0}, {4, 0}, {4, 2}, {2, 2}, {2, 0}, {1, 0}, {1, -2}, {0, -2}},
+			want: []Violation{
+				{SelfIntersection, []int{0, 1, 3, 4}},
+				{SelfIntersection, []int{0, 1, 4, 5}},
+				{SelfIntersection, []int{0, 1, 5, 6}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := validateLoop(tt.poly)
+			if len(got) != len(tt.want) {
+				t.Fatalf("validateLoop = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i].String() != tt.want[i].String() {
+					t.Errorf("violation %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRepairLoop(t *testing.T) {
+	layout := Layout{
+		Points: []Point{{0, 0}, {2, 0}, {2, 0}, {4, 0}, {4, 4}, {4, 4}, {0, 4}, {0, 2}},
+		Lines:  []int{1, 2, 3, 4, 5, 6, 8, 9},
+		Loops:  []Loop{{Start: 0, End: 8}},
+	}
+	if removed := layout.Repair(); removed != 4 {
+		t.Errorf("Repair removed %d tiles, want 4", removed)
+	}
+
+	wantPoints := []Point{{0, 0}, {4, 0}, {4, 4}, {0, 4}}
+	wantLines := []int{1, 4, 5, 8}
+	if !slices.Equal(layout.Points, wantPoints) || !slices.Equal(layout.Lines, wantLines) {
+		t.Errorf("repaired = %v on lines %v, want %v on lines %v", layout.Points, layout.Lines, wantPoints, wantLines)
+	}
+	if v := validateLoop(layout.Points); len(v) != 0 {
+		t.Errorf("repaired loop still has violations %v", v)
+	}
+
+	// Repair leaves shape-changing problems alone.
+	spike := []Point{{0, 0}, {4, 0}, {4, 4}, {4, 2}, {0, 2}}
+	if got, _ := repairLoop(spike); !slices.Equal(got, spike) {
+		t.Errorf("repairLoop(spike) = %v, want it unchanged", got)
+	}
+}
+
+func TestWriteSVG(t *testing.T) {
+	layout, best1, best2 := solve("test.txt", SolveOptions{})
+
+	var sb strings.Builder
+	if err := layout.WriteSVG(&sb, best1, best2); err != nil {
+		t.Fatalf("WriteSVG() error: %v", err)
+	}
+	out := sb.String()
+
+	dec := xml.NewDecoder(strings.NewReader(out))
+	for {
+		if _, err := dec.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("WriteSVG() produced invalid XML: %v", err)
+		}
+	}
+
+	if got := strings.Count(out, `class="tile"`); got != 8 {
+		t.Errorf("SVG has %d red tiles; want 8", got)
+	}
+	if !strings.Contains(out, "<title>9,5 on line 5</title>") {
+		t.Errorf("SVG is missing the input coordinates of red tiles")
+	}
+	// The example spans 10 columns, so each tile is 80 units wide. The part 2
+	// rectangle covers normalised columns 0-7 and rows 2-4.
+	part2 := `x="50.00" y="210.00" width="640.00" height="240.00" fill="` + part2Colour + `"`
+	if !strings.Contains(out, part2) {
+		t.Errorf("SVG does not draw the part 2 rectangle at %s:\n%s", part2, out)
+	}
+	part1 := `x="50.00" y="50.00" width="800.00" height="400.00" fill="` + part1Colour + `"`
+	if !strings.Contains(out, part1) {
+		t.Errorf("SVG does not draw the part 1 rectangle at %s", part1)
+	}
+}
+
+func TestTicks(t *testing.T) {
+	tests := []struct {
+		span int64
+		want []int64
+	}{
+		{0, []int64{0}},
+		{4, []int64{0, 1, 2, 3, 4}},
+		{9, []int64{0, 2, 4, 6, 8}},
+		{95000, []int64{0, 20000, 40000, 60000, 80000}},
+	}
+	for _, tt := range tests {
+		if got := ticks(tt.span); !slices.Equal(got, tt.want) {
+			t.Errorf("ticks(%d) = %v, want %v", tt.span, got, tt.want)
+		}
+	}
+}
+
+// courtyards is a floor with a forbidden courtyard and a second floor area
+// beside it.
+const courtyards = `outer
+0,0
+20,0
+20,12
+0,12
+
+hole
+4,3
+12,3
+12,8
+4,8
+
+outer
+26,0
+31,0
+31,4
+26,4
+`
+
+func writeInput(t *testing.T, input string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "tiles.txt")
+	if err := os.WriteFile(filename, []byte(input), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestSolveWithHoles(t *testing.T) {
+	layout, best1, best2 := solve(writeInput(t, courtyards), SolveOptions{})
+
+	want := []Loop{{0, 4, false}, {4, 8, true}, {8, 12, false}}
+	if !slices.Equal(layout.Loops, want) {
+		t.Errorf("Loops = %v, want %v", layout.Loops, want)
+	}
+	if want := []int{2, 3, 4, 5, 8, 9, 10, 11, 14, 15, 16, 17}; !slices.Equal(layout.Lines, want) {
+		t.Errorf("Lines = %v, want %v", layout.Lines, want)
+	}
+
+	// Part 1 ignores the loops and spans both floor areas.
+	if got := layout.describe(best1); got != "416 (0,12 on line 5 to 31,0 on line 15)" {
+		t.Errorf("part 1 = %s", got)
+	}
+	// The whole first floor would be 273, but the courtyard is in the way;
+	// the best rectangle runs along its right side and may touch its loop.
+	if got := layout.describe(best2); got != "90 (20,12 on line 4 to 12,3 on line 9)" {
+		t.Errorf("part 2 = %s", got)
+	}
+}
+
+func TestTileGridWithHolesMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(45))
+	outer := []Point{{0, 0}, {12, 0}, {12, 10}, {0, 10}}
+	island := []Point{{5, 4}, {6, 4}, {6, 5}, {5, 5}}
+	for it := 0; it < 100; it++ {
+		// A staircase courtyard inside the outer loop, sometimes with an
+		// island of floor in the middle of it.
+		hole := staircase(rng, 1+rng.Intn(3), it%2 == 1)
+		for i := range hole {
+			hole[i].X += 3
+			hole[i].Y += 3
+		}
+		loops := [][]Point{outer, hole}
+		if it%3 == 0 {
+			loops = append(loops, island)
+		}
+		if slices.ContainsFunc(hole, func(p Point) bool { return p.X >= 12 || p.Y >= 10 }) {
+			continue
+		}
+
+		grid, err := newTileGrid(loops...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		floor := func(p Point) bool {
+			enclosing := 0
+			for _, poly := range loops {
+				if onBoundary(p, poly) {
+					return true
+				}
+				if pointInPolygon(p, poly) {
+					enclosing++
+				}
+			}
+			return enclosing%2 == 1
+		}
+
+		for q := 0; q < 200; q++ {
+			a := Point{rng.Int63n(15) - 1, rng.Int63n(13) - 1}
+			b := Point{rng.Int63n(15) - 1, rng.Int63n(13) - 1}
+			want := true
+			for x := min64(a.X, b.X); x <= max64(a.X, b.X) && want; x++ {
+				for y := min64(a.Y, b.Y); y <= max64(a.Y, b.Y) && want; y++ {
+					want = floor(Point{x, y})
+				}
+			}
+			if got := grid.contains(a, b); got != want {
+				t.Fatalf("loops %v: contains(%v,%v) = %v, want %v", loops, a, b, got, want)
+			}
+		}
+	}
+}
+
+func TestValidateLayoutLoops(t *testing.T) {
+	type square struct {
+		x, y, size int64
+		hole       bool
+	}
+	build := func(squares ...square) Layout {
+		var l Layout
+		for _, sq := range squares {
+			start := len(l.Points)
+			l.Points = append(l.Points,
+				Point{sq.x, sq.y}, Point{sq.x + sq.size, sq.y},
+				Point{sq.x + sq.size, sq.y + sq.size}, Point{sq.x, sq.y + sq.size})
+			l.Loops = append(l.Loops, Loop{Start: start, End: len(l.Points), Hole: sq.hole})
+		}
+		l.Lines = make([]int, len(l.Points))
+		return l
+	}
+
+	tests := []struct {
+		name    string
+		squares []square
+		want    []string
+	}{
+		{"side by side", []square{{0, 0, 4, false}, {6, 0, 4, false}}, nil},
+		{"courtyard", []square{{0, 0, 10, false}, {2, 2, 4, true}}, nil},
+		{"island in a courtyard", []square{{0, 0, 10, false}, {2, 2, 6, true}, {4, 4, 2, false}}, nil},
+		{"stray hole", []square{{0, 0, 4, false}, {6, 0, 2, true}}, []string{"hole outside every outer loop at vertices 4"}},
+		{"outer in outer", []square{{0, 0, 10, false}, {2, 2, 4, false}}, []string{"outer loop inside another outer loop at vertices 4"}},
+		{"hole in a hole", []square{{0, 0, 10, false}, {1, 1, 8, true}, {2, 2, 4, true}}, []string{"hole outside every outer loop at vertices 8"}},
+		{
+			"touching walls",
+			[]square{{0, 0, 4, false}, {4, 1, 2, false}},
+			[]string{"loops intersect at vertices 1, 2, 4, 5", "loops intersect at vertices 1, 2, 6, 7", "loops intersect at vertices 1, 2, 7, 4"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, v := range build(tt.squares...).validate() {
+				got = append(got, v.String())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("validate() = %q, want %q", got, tt.want)
+			}
+		})
 	}
 }
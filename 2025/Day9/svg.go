@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// svgPlot is the longer side of the plotted area in SVG units and
+// svgMargin the room left for the axes around it.
+const (
+	svgPlot   = 800.0
+	svgMargin = 50.0
+)
+
+const (
+	loopColour  = "#2e9e44"
+	tileColour  = "#d7191c"
+	part1Colour = "#2c7bb6"
+	part2Colour = "#f2a900"
+	axisColour  = "#333333"
+)
+
+// WriteSVG plots the layout in its normalised coordinates: every loop, with
+// holes dashed, a dot on every red tile, and the best rectangle of part 1
+// and of part 2. Each tile is a unit square, so a rectangle covers its
+// corner tiles fully and the loops run through tile centres. Hovering a
+// tile shows its input coordinates and line.
+func (l Layout) WriteSVG(w io.Writer, best1, best2 Rectangle) error {
+	var spanX, spanY int64
+	for _, p := range l.Points {
+		spanX, spanY = max(spanX, p.X), max(spanY, p.Y)
+	}
+	scale := svgPlot / float64(max(spanX, spanY)+1)
+	width := float64(spanX+1)*scale + 2*svgMargin
+	height := float64(spanY+1)*scale + 2*svgMargin + 20
+
+	// px maps a normalised coordinate to SVG units along either axis.
+	px := func(v float64) float64 { return svgMargin + v*scale }
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f">`+"\n",
+		width, height, width, height)
+	fmt.Fprintf(&sb, `<rect width="%.0f" height="%.0f" fill="#ffffff"/>`+"\n", width, height)
+
+	writeAxes(&sb, spanX, spanY, px)
+
+	// best rectangles, part 1 underneath
+	for _, r := range []struct {
+		rect    Rectangle
+		colour  string
+		opacity float64
+		label   string
+	}{
+		{best1, part1Colour, 0.15, "part 1"},
+		{best2, part2Colour, 0.35, "part 2"},
+	} {
+		if r.rect.Area == 0 {
+			continue
+		}
+		a, b := l.Points[r.rect.I], l.Points[r.rect.J]
+		x1, x2 := float64(min64(a.X, b.X)), float64(max64(a.X, b.X)+1)
+		y1, y2 := float64(min64(a.Y, b.Y)), float64(max64(a.Y, b.Y)+1)
+		fmt.Fprintf(&sb, `<rect class="best" x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="%s" fill-opacity="%.2f" stroke="%s" stroke-width="2"><title>%s: %s</title></rect>`+"\n",
+			px(x1), px(y1), px(x2)-px(x1), px(y2)-px(y1), r.colour, r.opacity, r.colour, r.label, l.describe(r.rect))
+	}
+
+	// loops, holes dashed
+	for k, lp := range l.Loops {
+		points := make([]string, 0, lp.End-lp.Start)
+		for _, p := range l.loop(k) {
+			points = append(points, fmt.Sprintf("%.2f,%.2f", px(float64(p.X)+0.5), px(float64(p.Y)+0.5)))
+		}
+		dash := ""
+		if lp.Hole {
+			dash = ` stroke-dasharray="6,3"`
+		}
+		fmt.Fprintf(&sb, `<polygon class="loop" points="%s" fill="none" stroke="%s" stroke-width="1.5" stroke-linejoin="round"%s/>`+"\n",
+			strings.Join(points, " "), loopColour, dash)
+	}
+
+	// red tiles
+	radius := max(1.5, scale*0.4)
+	fmt.Fprintf(&sb, `<g fill="%s">`+"\n", tileColour)
+	for i, p := range l.Points {
+		in := l.Input(i)
+		fmt.Fprintf(&sb, `<circle class="tile" cx="%.2f" cy="%.2f" r="%.2f"><title>%d,%d on line %d</title></circle>`+"\n",
+			px(float64(p.X)+0.5), px(float64(p.Y)+0.5), radius, in.X, in.Y, l.Lines[i])
+	}
+	sb.WriteString("</g>\n")
+
+	fmt.Fprintf(&sb, `<text x="%.0f" y="%.0f" font-family="monospace" font-size="12" fill="%s">origin %d,%d   <tspan fill="%s">part 1: %d</tspan>   <tspan fill="%s">part 2: %d</tspan></text>`+"\n",
+		svgMargin, height-10, axisColour, l.Origin.X, l.Origin.Y, part1Colour, best1.Area, part2Colour, best2.Area)
+	sb.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// writeAxes draws an X axis above the plot and a Y axis to its left, with
+// ticks at round numbers of the normalised coordinates.
+func writeAxes(sb *strings.Builder, spanX, spanY int64, px func(float64) float64) {
+	fmt.Fprintf(sb, `<g stroke="%s" stroke-width="1">`+"\n", axisColour)
+	fmt.Fprintf(sb, `<line x1="%.2f" y1="%.2f" x2="%.2f" y2="%.2f"/>`+"\n", px(0), px(0), px(float64(spanX+1)), px(0))
+	fmt.Fprintf(sb, `<line x1="%.2f" y1="%.2f" x2="%.2f" y2="%.2f"/>`+"\n", px(0), px(0), px(0), px(float64(spanY+1)))
+	sb.WriteString("</g>\n")
+
+	fmt.Fprintf(sb, `<g font-family="monospace" font-size="10" fill="%s">`+"\n", axisColour)
+	for _, v := range ticks(spanX) {
+		x := px(float64(v) + 0.5)
+		fmt.Fprintf(sb, `<line x1="%.2f" y1="%.2f" x2="%.2f" y2="%.2f" stroke="%s"/>`+"\n", x, px(0)-4, x, px(0), axisColour)
+		fmt.Fprintf(sb, `<text x="%.2f" y="%.2f" text-anchor="middle">%d</text>`+"\n", x, px(0)-6, v)
+	}
+	for _, v := range ticks(spanY) {
+		y := px(float64(v) + 0.5)
+		fmt.Fprintf(sb, `<line x1="%.2f" y1="%.2f" x2="%.2f" y2="%.2f" stroke="%s"/>`+"\n", px(0)-4, y, px(0), y, axisColour)
+		fmt.Fprintf(sb, `<text x="%.2f" y="%.2f" text-anchor="end" dominant-baseline="middle">%d</text>`+"\n", px(0)-6, y, v)
+	}
+	sb.WriteString("</g>\n")
+}
+
+// ticks returns about five evenly spaced round values from 0 to span.
+func ticks(span int64) []int64 {
+	step := int64(1)
+	if span > 5 {
+		raw := float64(span) / 5
+		mag := math.Pow(10, math.Floor(math.Log10(raw)))
+		for _, m := range []float64{1, 2, 5, 10} {
+			if m*mag >= raw {
+				step = int64(m * mag)
+				break
+			}
+		}
+	}
+
+	var vals []int64
+	for v := int64(0); v <= span; v += step {
+		vals = append(vals, v)
+	}
+	return vals
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ViolationKind is a way in which the red tiles fail to form simple,
+// closed, axis-aligned loops.
+type ViolationKind int
+
+const (
+	// TooFewVertices: a loop needs at least four corners.
+	TooFewVertices ViolationKind = iota
+	// DuplicateVertex: two consecutive tiles are the same, a zero-length
+	// edge. Repairable.
+	DuplicateVertex
+	// CollinearVertex: a tile lies in the middle of a straight edge instead
+	// of at a corner. Repairable.
+	CollinearVertex
+	// DiagonalEdge: consecutive tiles share neither row nor column.
+	DiagonalEdge
+	// RepeatedPoint: the loop visits the same tile twice.
+	RepeatedPoint
+	// SelfIntersection: two edges cross, touch or overlap, including an
+	// edge that doubles back over the previous one.
+	SelfIntersection
+	// LoopsIntersect: edges of two different loops cross, touch or overlap.
+	LoopsIntersect
+	// StrayHole: a hole is not inside an outer loop.
+	StrayHole
+	// NestedOuter: an outer loop is inside another outer loop without a
+	// hole between them.
+	NestedOuter
+)
+
+func (k ViolationKind) String() string {
+	switch k {
+	case TooFewVertices:
+		return "too few vertices"
+	case DuplicateVertex:
+		return "duplicate vertex"
+	case CollinearVertex:
+		return "collinear vertex"
+	case DiagonalEdge:
+		return "diagonal edge"
+	case RepeatedPoint:
+		return "repeated point"
+	case SelfIntersection:
+		return "self-intersection"
+	case LoopsIntersect:
+		return "loops intersect"
+	case StrayHole:
+		return "hole outside every outer loop"
+	case NestedOuter:
+		return "outer loop inside another outer loop"
+	}
+	return fmt.Sprintf("ViolationKind(%d)", int(k))
+}
+
+// Violation is one problem with the loop and the indices of the vertices
+// involved, in loop order.
+type Violation struct {
+	Kind     ViolationKind
+	Vertices []int
+}
+
+func (v Violation) String() string {
+	if len(v.Vertices) == 0 {
+		return v.Kind.String()
+	}
+	return fmt.Sprintf("%s at vertices %s", v.Kind, joinInts(v.Vertices))
+}
+
+// repairable reports whether repairLoop removes this kind of violation.
+func (k ViolationKind) repairable() bool {
+	return k == DuplicateVertex || k == CollinearVertex
+}
+
+// validateLoop checks that poly, closed by wrapping around, is a simple
+// axis-aligned loop and returns every violation found, ordered by kind and
+// then by vertex.
+func validateLoop(poly []Point) []Violation {
+	n := len(poly)
+	if n < 4 {
+		all := make([]int, n)
+		for i := range all {
+			all[i] = i
+		}
+		return []Violation{{Kind: TooFewVertices, Vertices: all}}
+	}
+
+	var found [SelfIntersection + 1][]Violation
+	add := func(k ViolationKind, vertices ...int) {
+		found[k] = append(found[k], Violation{Kind: k, Vertices: vertices})
+	}
+
+	for i := 0; i < n; i++ {
+		prev, next := (i+n-1)%n, (i+1)%n
+		a, b := poly[i], poly[next]
+		switch {
+		case a == b:
+			add(DuplicateVertex, i, next)
+			continue
+		case a.X != b.X && a.Y != b.Y:
+			add(DiagonalEdge, i, next)
+		}
+
+		// The turn at vertex i, skipping when an adjacent edge has no
+		// length; that is already a duplicate.
+		p := poly[prev]
+		if p == a || orient(p, a, b) != 0 {
+			continue
+		}
+		if sameDirection(Point{p.X - a.X, p.Y - a.Y}, Point{b.X - a.X, b.Y - a.Y}) {
+			add(SelfIntersection, prev, i, next)
+		} else {
+			add(CollinearVertex, prev, i, next)
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			if poly[i] == poly[j] && j != i+1 && !(i == 0 && j == n-1) {
+				add(RepeatedPoint, i, j)
+			}
+		}
+	}
+
+	// Edges i and j that are not neighbours in the loop must not share a
+	// single tile. Neighbouring edges were checked at their common vertex.
+	for i := 0; i < n; i++ {
+		a, b := poly[i], poly[(i+1)%n]
+		if a == b {
+			continue
+		}
+		for j := i + 2; j < n; j++ {
+			if i == 0 && j == n-1 {
+				continue
+			}
+			c, d := poly[j], poly[(j+1)%n]
+			if c == d || !segmentsTouch(a, b, c, d) {
+				continue
+			}
+			// Touching only at an endpoint both edges have is a repeated
+			// point, reported above.
+			if (a == c || a == d || b == c || b == d) && !collinearOverlap(a, b, c, d) {
+				continue
+			}
+			add(SelfIntersection, i, (i+1)%n, j, (j+1)%n)
+		}
+	}
+
+	var all []Violation
+	for _, vs := range found {
+		all = append(all, vs...)
+	}
+	return all
+}
+
+// validate checks every loop with validateLoop, then checks that loops do
+// not touch each other and that holes and outer loops alternate when
+// nested. Vertex indices refer to l.Points.
+func (l Layout) validate() []Violation {
+	var all []Violation
+	for k, lp := range l.Loops {
+		for _, v := range validateLoop(l.loop(k)) {
+			for i := range v.Vertices {
+				v.Vertices[i] += lp.Start
+			}
+			all = append(all, v)
+		}
+	}
+
+	for k, lk := range l.Loops {
+		for m := k + 1; m < len(l.Loops); m++ {
+			lm := l.Loops[m]
+			for i := lk.Start; i < lk.End; i++ {
+				i2 := lk.Start + (i-lk.Start+1)%(lk.End-lk.Start)
+				for j := lm.Start; j < lm.End; j++ {
+					j2 := lm.Start + (j-lm.Start+1)%(lm.End-lm.Start)
+					if segmentsTouch(l.Points[i], l.Points[i2], l.Points[j], l.Points[j2]) {
+						all = append(all, Violation{Kind: LoopsIntersect, Vertices: []int{i, i2, j, j2}})
+					}
+				}
+			}
+		}
+	}
+
+	// A loop's depth is the number of other loops around it. Loops do not
+	// touch, so one vertex decides it.
+	for k, lk := range l.Loops {
+		if lk.Start == lk.End {
+			continue
+		}
+		p := l.Points[lk.Start]
+		depth := 0
+		for m := range l.Loops {
+			if m != k && pointInPolygon(p, l.loop(m)) && !onBoundary(p, l.loop(m)) {
+				depth++
+			}
+		}
+		switch {
+		case lk.Hole && depth%2 == 0:
+			all = append(all, Violation{Kind: StrayHole, Vertices: []int{lk.Start}})
+		case !lk.Hole && depth%2 == 1:
+			all = append(all, Violation{Kind: NestedOuter, Vertices: []int{lk.Start}})
+		}
+	}
+	return all
+}
+
+// segmentsTouch reports whether segments ab and cd share at least one point.
+func segmentsTouch(a, b, c, d Point) bool {
+	return properSegmentIntersect(a, b, c, d) ||
+		onSegment(c, a, b) || onSegment(d, a, b) ||
+		onSegment(a, c, d) || onSegment(b, c, d)
+}
+
+// collinearOverlap reports whether segments ab and cd lie on one line and
+// share more than a single point.
+func collinearOverlap(a, b, c, d Point) bool {
+	if orient(a, b, c) != 0 || orient(a, b, d) != 0 {
+		return false
+	}
+	shared := 0
+	for _, p := range []Point{c, d} {
+		if onSegment(p, a, b) {
+			shared++
+		}
+	}
+	for _, p := range []Point{a, b} {
+		if onSegment(p, c, d) && p != c && p != d {
+			shared++
+		}
+	}
+	return shared >= 2
+}
+
+// sameDirection reports whether collinear, non-zero vectors u and v point
+// the same way.
+func sameDirection(u, v Point) bool {
+	if u.X != 0 {
+		return (u.X > 0) == (v.X > 0)
+	}
+	return (u.Y > 0) == (v.Y > 0)
+}
+
+// repairLoop removes duplicate consecutive vertices and vertices in the
+// middle of straight edges, the violations that do not change the loop's
+// shape. It returns the remaining vertices and, for each, its index in
+// poly.
+func repairLoop(poly []Point) ([]Point, []int) {
+	kept := make([]int, len(poly))
+	for i := range kept {
+		kept[i] = i
+	}
+
+	for changed := true; changed && len(kept) > 2; {
+		changed = false
+		for k := 0; k < len(kept) && len(kept) > 2; k++ {
+			n := len(kept)
+			p, a, b := poly[kept[(k+n-1)%n]], poly[kept[k]], poly[kept[(k+1)%n]]
+			straight := p != a && a != b && orient(p, a, b) == 0 &&
+				!sameDirection(Point{p.X - a.X, p.Y - a.Y}, Point{b.X - a.X, b.Y - a.Y})
+			drop := -1
+			switch {
+			case a == b:
+				// Keep the first of the two, counting from the start of
+				// the input.
+				drop = (k + 1) % n
+				if drop == 0 {
+					drop = k
+				}
+			case straight:
+				drop = k
+			}
+			if drop >= 0 {
+				kept = append(kept[:drop], kept[drop+1:]...)
+				changed = true
+				k--
+			}
+		}
+	}
+
+	repaired := make([]Point, len(kept))
+	for k, i := range kept {
+		repaired[k] = poly[i]
+	}
+	return repaired, kept
+}
+
+// Repair applies repairLoop to every loop of the layout, keeping line
+// numbers aligned, and returns how many tiles it removed.
+func (l *Layout) Repair() int {
+	var points []Point
+	var lines []int
+	loops := make([]Loop, len(l.Loops))
+	for k, lp := range l.Loops {
+		repaired, kept := repairLoop(l.loop(k))
+		loops[k] = Loop{Start: len(points), End: len(points) + len(repaired), Hole: lp.Hole}
+		points = append(points, repaired...)
+		for _, i := range kept {
+			lines = append(lines, l.Lines[lp.Start+i])
+		}
+	}
+
+	removed := len(l.Points) - len(points)
+	l.Points, l.Lines, l.Loops = points, lines, loops
+	return removed
+}
+
+// explain describes v with the input line and coordinates of every vertex.
+func (l Layout) explain(v Violation) string {
+	parts := make([]string, len(v.Vertices))
+	for k, i := range v.Vertices {
+		p := l.Input(i)
+		parts[k] = fmt.Sprintf("%d,%d on line %d", p.X, p.Y, l.Lines[i])
+	}
+	return fmt.Sprintf("%s: %s", v, strings.Join(parts, ", "))
+}
+
+func joinInts(vals []int) string {
+	parts := make([]string, len(vals))
+	for i, v := range vals {
+		parts[i] = fmt.Sprint(v)
+	}
+	return strings.Join(parts, ", ")
+}